@@ -0,0 +1,246 @@
+package v8
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const accessLogTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+var (
+	// CombinedLogFormat is an AccessLogFormatter for the Apache Combined Log Format with the bytes received and sent
+	// appended, as in the combinedio format, like
+	// 127.0.0.1 - joe [10/Oct/2000:13:55:36 -0700] "GET /index.html HTTP/1.1" 200 2326 "-" "curl/8.0" 112 2491.
+	CombinedLogFormat AccessLogFormatter = AccessLogFormatterFunc(formatCombined)
+	// JSONLogFormat is an AccessLogFormatter for a compact JSON object per request.
+	JSONLogFormat AccessLogFormatter = AccessLogFormatterFunc(formatJSON)
+	// ECSLogFormat is an AccessLogFormatter for a JSON document with Elastic Common Schema fields per request.
+	ECSLogFormat AccessLogFormatter = AccessLogFormatterFunc(formatECS)
+)
+
+type (
+	// AccessLogEntry contains the properties of a finished request, passed to an AccessLogFormatter.
+	AccessLogEntry struct {
+		Time      time.Time
+		Method    string
+		URL       string
+		Path      string
+		Query     string
+		Protocol  string
+		ClientIP  string
+		User      string
+		Referer   string
+		UserAgent string
+		RequestID string
+		Status    int
+		BytesIn   int64
+		BytesOut  int64
+		Duration  time.Duration
+		// Meta contains the log meta of the request as logged by the ApiResponse message, including Fields.
+		Meta map[string]string
+		// Fields contains the meta of the route and the request meta added by handlers and middleware.
+		Fields map[string]string
+	}
+
+	// AccessLogFormatter is an interface for formatting the single log message per request of the RequestLogging
+	// middleware.
+	AccessLogFormatter interface {
+		// Format returns the message and the log meta for the entry.
+		Format(entry *AccessLogEntry) (message string, meta map[string]string)
+	}
+
+	// AccessLogFormatterFunc is a function signature to implement a custom AccessLogFormatter.
+	AccessLogFormatterFunc func(entry *AccessLogEntry) (message string, meta map[string]string)
+
+	// accessLogJSON is the schema of JSONLogFormat.
+	accessLogJSON struct {
+		Time       string  `json:"time"`
+		Method     string  `json:"method"`
+		URL        string  `json:"url"`
+		Protocol   string  `json:"protocol"`
+		Status     int     `json:"status"`
+		DurationMs float64 `json:"duration_ms"`
+		BytesIn    int64   `json:"bytes_in"`
+		BytesOut   int64   `json:"bytes_out"`
+		ClientIP   string  `json:"client_ip,omitempty"`
+		User       string  `json:"user,omitempty"`
+		Referer    string  `json:"referer,omitempty"`
+		UserAgent  string  `json:"user_agent,omitempty"`
+		RequestID  string  `json:"request_id,omitempty"`
+	}
+
+	byteCountReader struct {
+		io.ReadCloser
+		n int64
+	}
+
+	byteCountWriter struct {
+		WrappedResponseWriter
+		n int64
+	}
+)
+
+/* AccessLogFormatter implementation */
+
+func (f AccessLogFormatterFunc) Format(entry *AccessLogEntry) (string, map[string]string) {
+	return f(entry)
+}
+
+func formatCombined(e *AccessLogEntry) (string, map[string]string) {
+	bytesOut := "-"
+	if e.BytesOut > 0 {
+		bytesOut = strconv.FormatInt(e.BytesOut, 10)
+	}
+
+	message := fmt.Sprintf(`%s - %s [%s] "%s %s %s" %d %s "%s" "%s" %d %d`,
+		orDash(e.ClientIP),
+		orDash(e.User),
+		e.Time.Format(accessLogTimeFormat),
+		e.Method,
+		e.Path+queryString(e.Query),
+		e.Protocol,
+		e.Status,
+		bytesOut,
+		orDash(e.Referer),
+		orDash(e.UserAgent),
+		e.BytesIn,
+		e.BytesOut,
+	)
+	return message, e.Meta
+}
+
+func formatJSON(e *AccessLogEntry) (string, map[string]string) {
+	data, _ := json.Marshal(accessLogJSON{
+		Time:       e.Time.UTC().Format(time.RFC3339Nano),
+		Method:     e.Method,
+		URL:        e.URL,
+		Protocol:   e.Protocol,
+		Status:     e.Status,
+		DurationMs: float64(e.Duration.Microseconds()) / 1000.0,
+		BytesIn:    e.BytesIn,
+		BytesOut:   e.BytesOut,
+		ClientIP:   e.ClientIP,
+		User:       e.User,
+		Referer:    e.Referer,
+		UserAgent:  e.UserAgent,
+		RequestID:  e.RequestID,
+	})
+	return string(data), e.Meta
+}
+
+func formatECS(e *AccessLogEntry) (string, map[string]string) {
+	doc := make(map[string]interface{})
+
+	setECS := func(key string, value interface{}) {
+		if value == "" {
+			return
+		}
+		names := strings.Split(key, ".")
+		node := doc
+		for _, name := range names[:len(names)-1] {
+			child, ok := node[name].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				node[name] = child
+			}
+			node = child
+		}
+		node[names[len(names)-1]] = value
+	}
+	setECS("@timestamp", e.Time.UTC().Format(time.RFC3339Nano))
+	setECS("event.duration", e.Duration.Nanoseconds())
+	setECS("http.request.method", e.Method)
+	setECS("http.request.id", e.RequestID)
+	setECS("http.request.referrer", e.Referer)
+	setECS("http.request.body.bytes", e.BytesIn)
+	setECS("http.request.body.content", e.Meta["entry.http.request.body"])
+	setECS("http.response.status_code", e.Status)
+	setECS("http.response.body.bytes", e.BytesOut)
+	setECS("http.response.body.content", e.Meta["entry.http.response.body"])
+	setECS("http.response.mime_type", e.Meta["entry.http.header.content-type"])
+	setECS("http.version", strings.TrimPrefix(e.Protocol, "HTTP/"))
+	setECS("url.full", e.URL)
+	setECS("url.path", e.Path)
+	setECS("url.query", e.Query)
+	setECS("url.scheme", e.Meta["entry.http.scheme"])
+	setECS("client.ip", e.ClientIP)
+	setECS("user.name", e.User)
+	setECS("user_agent.original", e.UserAgent)
+
+	if len(e.Fields) > 0 {
+		// ECS labels are flat, so the dots of the field names are replaced.
+		labels := make(map[string]string)
+		for key, value := range e.Fields {
+			labels[strings.ReplaceAll(strings.TrimPrefix(key, "entry."), ".", "_")] = value
+		}
+		doc["labels"] = labels
+	}
+
+	data, _ := json.Marshal(doc)
+	return string(data), e.Meta
+}
+
+// newAccessLogEntry creates the entry for a finished request from its log meta.
+func newAccessLogEntry(r *http.Request, status int, start time.Time, bytesIn, bytesOut int64,
+	meta, fields map[string]string) *AccessLogEntry {
+
+	user := meta["entry.auth.sub"]
+	if user == "" {
+		user = IdentityFromContext(r.Context())
+	}
+
+	return &AccessLogEntry{
+		Time:      start,
+		Method:    r.Method,
+		URL:       meta["entry.http.url"],
+		Path:      r.URL.Path,
+		Query:     r.URL.RawQuery,
+		Protocol:  r.Proto,
+		ClientIP:  ClientIP(r),
+		User:      user,
+		Referer:   r.Referer(),
+		UserAgent: r.UserAgent(),
+		RequestID: RequestIDFromContext(r.Context()),
+		Status:    status,
+		BytesIn:   bytesIn,
+		BytesOut:  bytesOut,
+		Duration:  time.Since(start),
+		Meta:      meta,
+		Fields:    fields,
+	}
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+func queryString(query string) string {
+	if query == "" {
+		return ""
+	}
+	return "?" + query
+}
+
+/* io.Reader implementation */
+
+func (b *byteCountReader) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+/* http.ResponseWriter implementation */
+
+func (b *byteCountWriter) Write(p []byte) (int, error) {
+	n, err := b.WrappedResponseWriter.Write(p)
+	b.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,177 @@
+package v8_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	sf "github.com/Travix-International/go-servicefoundation/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newAccessLogEntry() *sf.AccessLogEntry {
+	return &sf.AccessLogEntry{
+		Time:      time.Date(2000, 10, 10, 13, 55, 36, 0, time.FixedZone("", -7*60*60)),
+		Method:    http.MethodPost,
+		URL:       "https://www.sf.com/orders?page=2",
+		Path:      "/orders",
+		Query:     "page=2",
+		Protocol:  "HTTP/1.1",
+		ClientIP:  "198.51.100.1",
+		User:      "joe",
+		UserAgent: "curl/8.0",
+		RequestID: "req-1",
+		Status:    http.StatusCreated,
+		BytesIn:   12,
+		BytesOut:  2326,
+		Duration:  1500 * time.Microsecond,
+		Meta:      map[string]string{"entry.http.scheme": "https", "entry.custom": "value"},
+		Fields:    map[string]string{"entry.custom": "value"},
+	}
+}
+
+func TestCombinedLogFormat(t *testing.T) {
+	entry := newAccessLogEntry()
+	anonymous := newAccessLogEntry()
+	anonymous.User, anonymous.UserAgent, anonymous.Query, anonymous.BytesOut = "", "", "", 0
+	anonymous.Referer = "https://www.sf.com/"
+
+	scenarios := []struct {
+		entry    *sf.AccessLogEntry
+		expected string
+	}{
+		{entry, `198.51.100.1 - joe [10/Oct/2000:13:55:36 -0700] "POST /orders?page=2 HTTP/1.1" 201 2326 "-" "curl/8.0" 12 2326`},
+		{anonymous, `198.51.100.1 - - [10/Oct/2000:13:55:36 -0700] "POST /orders HTTP/1.1" 201 - "https://www.sf.com/" "-" 12 0`},
+	}
+
+	for i, scenario := range scenarios {
+		// Act
+		message, meta := sf.CombinedLogFormat.Format(scenario.entry)
+
+		assert.Equal(t, scenario.expected, message, "Scenario %d", i)
+		assert.Equal(t, scenario.entry.Meta, meta, "Scenario %d", i)
+	}
+}
+
+func TestJSONLogFormat(t *testing.T) {
+	// Act
+	message, meta := sf.JSONLogFormat.Format(newAccessLogEntry())
+
+	assert.Equal(t, `{"time":"2000-10-10T20:55:36Z","method":"POST","url":"https://www.sf.com/orders?page=2",`+
+		`"protocol":"HTTP/1.1","status":201,"duration_ms":1.5,"bytes_in":12,"bytes_out":2326,`+
+		`"client_ip":"198.51.100.1","user":"joe","user_agent":"curl/8.0","request_id":"req-1"}`, message)
+	assert.Equal(t, "value", meta["entry.custom"])
+}
+
+func TestECSLogFormat(t *testing.T) {
+	// Act
+	message, meta := sf.ECSLogFormat.Format(newAccessLogEntry())
+
+	assert.JSONEq(t, `{
+		"@timestamp": "2000-10-10T20:55:36Z",
+		"event": {"duration": 1500000},
+		"http": {
+			"request": {"method": "POST", "id": "req-1", "body": {"bytes": 12}},
+			"response": {"status_code": 201, "body": {"bytes": 2326}},
+			"version": "1.1"
+		},
+		"url": {"full": "https://www.sf.com/orders?page=2", "path": "/orders", "query": "page=2", "scheme": "https"},
+		"client": {"ip": "198.51.100.1"},
+		"user": {"name": "joe"},
+		"user_agent": {"original": "curl/8.0"},
+		"labels": {"custom": "value"}
+	}`, message)
+	assert.Equal(t, "value", meta["entry.custom"])
+}
+
+func TestECSLogFormat_DefaultLogFactory(t *testing.T) {
+	reader, writer, err := os.Pipe()
+	assert.NoError(t, err)
+	stderr := os.Stderr
+	os.Stderr = writer
+	defer func() { os.Stderr = stderr }()
+
+	handle := func(w sf.WrappedResponseWriter, _ *http.Request, _ sf.RouterParams) {
+		w.WriteHeader(http.StatusOK)
+	}
+	metaFunc := func(_ *http.Request, _ sf.RouterParams) map[string]string {
+		return make(map[string]string)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/orders?page=2", nil)
+	r.Header.Set("User-Agent", "curl/8.0")
+
+	wrapper := sf.NewMiddlewareWrapper(sf.NewLogFactory("Info", nil), nil, &sf.CORSOptions{}, sf.ServiceGlobals{})
+	sut := sf.NewServiceHandlerFactory(wrapper, nil, nil, nil)
+	middlewares := []sf.Middleware{sf.RequestLoggingWith(sf.RequestLoggingOptions{Format: sf.ECSLogFormat})}
+
+	// Act
+	sut.Wrap("my-sub", "my-name", middlewares, handle, metaFunc)(httptest.NewRecorder(), r, sf.RouterParams{})
+	writer.Close()
+
+	output, _ := io.ReadAll(reader)
+	var logged struct {
+		Payload struct {
+			Message string `json:"message"`
+		} `json:"payload"`
+	}
+	if assert.NoError(t, json.Unmarshal(output, &logged)) {
+		var doc map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(logged.Payload.Message), &doc))
+		assert.Equal(t, map[string]interface{}{"original": "curl/8.0"}, doc["user_agent"])
+		assert.Equal(t, map[string]interface{}{"full": "http://example.com/orders?page=2", "path": "/orders",
+			"query": "page=2", "scheme": "http"}, doc["url"])
+	}
+}
+
+func TestMiddlewareWrapperImpl_Wrap_RequestLoggingFormat(t *testing.T) {
+	logFactory := &mockLogFactory{}
+	log := &mockLogger{}
+	m := &mockMetrics{}
+	var formatted *sf.AccessLogEntry
+	format := sf.AccessLogFormatterFunc(func(entry *sf.AccessLogEntry) (string, map[string]string) {
+		formatted = entry
+		return "formatted", map[string]string{"key": "value"}
+	})
+	handle := func(w sf.WrappedResponseWriter, r *http.Request, _ sf.RouterParams) {
+		io.ReadAll(r.Body)
+		sf.AddRequestMeta(r, "entry.order", "42")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("accepted"))
+	}
+	metaFunc := func(_ *http.Request, _ sf.RouterParams) map[string]string {
+		return map[string]string{"entry.route": "orders"}
+	}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/orders?page=2", strings.NewReader(`{"id":42}`))
+	r.Header.Set("User-Agent", "curl/8.0")
+
+	logFactory.On("NewLogger", mock.Anything).Return(log)
+	log.On("Info", "AccessLog", "formatted", mock.Anything).Return()
+
+	wrapper := sf.NewMiddlewareWrapper(logFactory, m, &sf.CORSOptions{}, sf.ServiceGlobals{})
+	sut := sf.NewServiceHandlerFactory(wrapper, nil, nil, nil)
+	middlewares := []sf.Middleware{sf.RequestLoggingWith(sf.RequestLoggingOptions{Format: format})}
+
+	// Act
+	sut.Wrap("my-sub", "my-name", middlewares, handle, metaFunc)(w, r, sf.RouterParams{})
+
+	log.AssertNumberOfCalls(t, "Info", 1)
+	logFactory.AssertCalled(t, "NewLogger", map[string]string{"key": "value"})
+	if assert.NotNil(t, formatted) {
+		assert.Equal(t, http.StatusAccepted, formatted.Status)
+		assert.Equal(t, int64(9), formatted.BytesIn)
+		assert.Equal(t, int64(8), formatted.BytesOut)
+		assert.Equal(t, "curl/8.0", formatted.UserAgent)
+		assert.Equal(t, "/orders", formatted.Path)
+		assert.Equal(t, "page=2", formatted.Query)
+		assert.Equal(t, "http://example.com/orders?page=2", formatted.URL)
+		assert.Equal(t, map[string]string{"entry.route": "orders", "entry.order": "42"}, formatted.Fields)
+		assert.Equal(t, "42", formatted.Meta["entry.order"])
+	}
+}
@@ -0,0 +1,53 @@
+package v8
+
+import (
+	"context"
+	"net/http"
+)
+
+type (
+	// HTTPMiddleware is a function signature for standard net/http middleware.
+	HTTPMiddleware func(http.Handler) http.Handler
+
+	routerParamsKey struct{}
+)
+
+// FromHTTPHandler converts a standard http.Handler to a Handle. The path parameters are made available in the request
+// context, see RouterParamsFromContext.
+func FromHTTPHandler(handler http.Handler) Handle {
+	return func(w WrappedResponseWriter, r *http.Request, p RouterParams) {
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), routerParamsKey{}, p)))
+	}
+}
+
+// ToHTTPHandler converts a Handle to a standard http.Handler. The path parameters are taken from the request context
+// when available.
+func ToHTTPHandler(handle Handle) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww, ok := w.(WrappedResponseWriter)
+		if !ok {
+			ww = NewWrappedResponseWriter(w)
+		}
+		handle(ww, r, RouterParamsFromContext(r.Context()))
+	})
+}
+
+// FromHTTPMiddleware converts standard net/http middleware to a function that wraps a Handle.
+func FromHTTPMiddleware(middleware HTTPMiddleware) func(Handle) Handle {
+	return func(handle Handle) Handle {
+		return FromHTTPHandler(middleware(ToHTTPHandler(handle)))
+	}
+}
+
+// ToHTTPMiddleware converts a function that wraps a Handle to standard net/http middleware.
+func ToHTTPMiddleware(middleware func(Handle) Handle) HTTPMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return ToHTTPHandler(middleware(FromHTTPHandler(handler)))
+	}
+}
+
+// RouterParamsFromContext returns the path parameters stored in the context by FromHTTPHandler.
+func RouterParamsFromContext(ctx context.Context) RouterParams {
+	p, _ := ctx.Value(routerParamsKey{}).(RouterParams)
+	return p
+}
@@ -0,0 +1,89 @@
+package v8_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	sf "github.com/Travix-International/go-servicefoundation/v8"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromHTTPHandler(t *testing.T) {
+	var actual sf.RouterParams
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actual = sf.RouterParamsFromContext(r.Context())
+		w.WriteHeader(http.StatusCreated)
+	})
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+	p := sf.RouterParams{Params: httprouter.Params{{Key: "id", Value: "42"}}}
+
+	// Act
+	sut := sf.FromHTTPHandler(handler)
+	sut(sf.NewWrappedResponseWriter(w), r, p)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "42", actual.ByName("id"))
+}
+
+func TestToHTTPHandler(t *testing.T) {
+	handle := func(w sf.WrappedResponseWriter, _ *http.Request, p sf.RouterParams) {
+		w.JSON(http.StatusOK, p.ByName("id"))
+	}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+	p := sf.RouterParams{Params: httprouter.Params{{Key: "id", Value: "42"}}}
+
+	// Act
+	sut := sf.ToHTTPHandler(handle)
+	sf.FromHTTPHandler(sut)(sf.NewWrappedResponseWriter(w), r, p)
+
+	assert.Equal(t, "\"42\"\n", w.Body.String())
+}
+
+func TestFromHTTPMiddleware(t *testing.T) {
+	order := ""
+	middleware := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			order += "middleware,"
+			w.Header().Set("X-Middleware", "yes")
+			next.ServeHTTP(w, r)
+		})
+	}
+	handle := func(w sf.WrappedResponseWriter, _ *http.Request, p sf.RouterParams) {
+		order += "handle:" + p.ByName("id")
+	}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+	p := sf.RouterParams{Params: httprouter.Params{{Key: "id", Value: "42"}}}
+
+	// Act
+	sut := sf.FromHTTPMiddleware(middleware)(handle)
+	sut(sf.NewWrappedResponseWriter(w), r, p)
+
+	assert.Equal(t, "middleware,handle:42", order)
+	assert.Equal(t, "yes", w.Header().Get("X-Middleware"))
+}
+
+func TestToHTTPMiddleware(t *testing.T) {
+	middleware := func(next sf.Handle) sf.Handle {
+		return func(w sf.WrappedResponseWriter, r *http.Request, p sf.RouterParams) {
+			w.Header().Set("X-Middleware", "yes")
+			next(w, r, p)
+		}
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	// Act
+	sut := sf.ToHTTPMiddleware(middleware)(handler)
+	sut.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "yes", w.Header().Get("X-Middleware"))
+}
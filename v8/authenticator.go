@@ -0,0 +1,277 @@
+package v8
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// APIKeyHeader is the default name of the http header containing an API key.
+	APIKeyHeader = "X-Api-Key"
+
+	shaPrefix = "{SHA}"
+)
+
+type (
+	// Authenticator is an interface for authenticating requests.
+	Authenticator interface {
+		// Authenticate returns the identity of the client, or false when the credentials are missing or invalid.
+		Authenticate(r *http.Request) (identity string, ok bool)
+		// Challenge returns the value of the WWW-Authenticate header for rejected requests, if any.
+		Challenge() string
+	}
+
+	// AuthenticatorFunc is a function signature to implement custom authentication. It returns the identity of the
+	// client, or false when the credentials are missing or invalid.
+	AuthenticatorFunc func(r *http.Request) (identity string, ok bool)
+
+	// CredentialSource is a function signature for loading credentials, as a map of identities to secrets.
+	CredentialSource func() (map[string]string, error)
+
+	// CredentialStore is an interface for holding credentials that can be reloaded without a restart.
+	CredentialStore interface {
+		// Credentials returns the current credentials, as a map of identities to secrets.
+		Credentials() map[string]string
+		// Reload loads the credentials from their source. When loading fails, the current credentials are kept.
+		Reload() error
+	}
+
+	credentialStoreImpl struct {
+		*reloadableSource
+	}
+
+	apiKeyAuthenticator struct {
+		header string
+		store  CredentialStore
+	}
+
+	basicAuthenticator struct {
+		realm string
+		store CredentialStore
+	}
+
+	identityKey struct{}
+)
+
+var (
+	dummyBcryptHash     []byte
+	dummyBcryptHashOnce sync.Once
+)
+
+// Authentication returns a Middleware enumeration that authenticates requests with the specified Authenticator.
+// Rejected requests get http status-code 401. The identity of the client is available through IdentityFromContext
+// and added to the log messages of the request as "entry.auth.sub". Use ServiceOptions.SubsystemMiddlewares to
+// protect a whole subsystem, like the internal endpoints.
+func Authentication(authenticator Authenticator) Middleware {
+	return registerRequestMetaMiddleware(func(m *middlewareWrapperImpl, subsystem, name string, handler Handle,
+		_ MetaFunc) Handle {
+
+		return m.wrapWithAuthentication(subsystem, name, handler, authenticator)
+	})
+}
+
+// IdentityFromContext returns the identity stored by the Authentication middleware, or an empty string when absent.
+func IdentityFromContext(ctx context.Context) string {
+	identity, _ := ctx.Value(identityKey{}).(string)
+	return identity
+}
+
+// NewCredentialStore instantiates a new CredentialStore implementation and loads the credentials. With a positive
+// reload interval, the credentials are reloaded when they are older than the interval; otherwise only Reload loads
+// them again.
+func NewCredentialStore(source CredentialSource, reloadInterval time.Duration) (CredentialStore, error) {
+	load := func() (interface{}, error) {
+		return source()
+	}
+
+	reloadable, err := newReloadableSource(load, reloadInterval)
+	return &credentialStoreImpl{reloadable}, err
+}
+
+// CredentialsFromFile returns a CredentialSource that reads "identity:secret" lines from the specified file, like an
+// htpasswd file. Empty lines and lines starting with # are skipped.
+func CredentialsFromFile(path string) CredentialSource {
+	return func() (map[string]string, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return parseCredentials(bytes.NewReader(data))
+	}
+}
+
+// CredentialsFromEnv returns a CredentialSource that reads a comma-separated list of "identity:secret" pairs from the
+// specified environment variable. Secrets without an identity are named "key1", "key2" and so on.
+func CredentialsFromEnv(name string) CredentialSource {
+	return func() (map[string]string, error) {
+		credentials := make(map[string]string)
+
+		for i, pair := range strings.Split(os.Getenv(name), ",") {
+			pair = strings.TrimSpace(pair)
+			if pair == "" {
+				continue
+			}
+
+			identity, secret, found := strings.Cut(pair, ":")
+			if !found {
+				identity, secret = "key"+strconv.Itoa(i+1), pair
+			}
+			credentials[identity] = secret
+		}
+		return credentials, nil
+	}
+}
+
+// NewAPIKeyAuthenticator instantiates an Authenticator that checks the API key in the specified header, with a
+// default of X-Api-Key, against the secrets of the store. The identity is the name of the matching key.
+func NewAPIKeyAuthenticator(header string, store CredentialStore) Authenticator {
+	if header == "" {
+		header = APIKeyHeader
+	}
+	return &apiKeyAuthenticator{header: header, store: store}
+}
+
+// NewBasicAuthenticator instantiates an Authenticator for basic authentication against htpasswd-style credentials,
+// with bcrypt ($2y$) or SHA-1 ({SHA}) hashed passwords. The identity is the user name.
+func NewBasicAuthenticator(realm string, store CredentialStore) Authenticator {
+	return &basicAuthenticator{realm: realm, store: store}
+}
+
+/* Authenticator implementation */
+
+func (f AuthenticatorFunc) Authenticate(r *http.Request) (string, bool) {
+	return f(r)
+}
+
+func (f AuthenticatorFunc) Challenge() string {
+	return ""
+}
+
+func (a *apiKeyAuthenticator) Authenticate(r *http.Request) (string, bool) {
+	key := r.Header.Get(a.header)
+	if key == "" {
+		return "", false
+	}
+
+	// Compare hashes of equal length against all keys, so the time taken does not reveal which key matched.
+	keyHash := sha256.Sum256([]byte(key))
+	identity := ""
+	found := 0
+
+	for name, secret := range a.store.Credentials() {
+		secretHash := sha256.Sum256([]byte(secret))
+		if subtle.ConstantTimeCompare(keyHash[:], secretHash[:]) == 1 {
+			identity = name
+			found = 1
+		}
+	}
+	return identity, found == 1
+}
+
+func (a *apiKeyAuthenticator) Challenge() string {
+	return ""
+}
+
+func (a *basicAuthenticator) Authenticate(r *http.Request) (string, bool) {
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return "", false
+	}
+
+	hash, exists := a.store.Credentials()[user]
+	if !exists {
+		// Spend the same time as for existing users, so the response time does not reveal valid user names.
+		bcrypt.CompareHashAndPassword(getDummyBcryptHash(), []byte(password))
+		return "", false
+	}
+	if !checkPassword(hash, password) {
+		return "", false
+	}
+	return user, true
+}
+
+func (a *basicAuthenticator) Challenge() string {
+	return `Basic realm="` + a.realm + `", charset="UTF-8"`
+}
+
+/* CredentialStore implementation */
+
+func (s *credentialStoreImpl) Credentials() map[string]string {
+	credentials, _ := s.get().(map[string]string)
+	return credentials
+}
+
+func (m *middlewareWrapperImpl) wrapWithAuthentication(subsystem, name string, handler Handle,
+	authenticator Authenticator) Handle {
+
+	return func(w WrappedResponseWriter, r *http.Request, p RouterParams) {
+		identity, ok := authenticator.Authenticate(r)
+
+		if !ok {
+			if challenge := authenticator.Challenge(); challenge != "" {
+				w.Header().Set("WWW-Authenticate", challenge)
+			}
+			writeErrorResponse(w, r, http.StatusUnauthorized, "Unauthorized")
+
+			labels, values := m.getLabelsAndValues(subsystem, name, w, r)
+			m.metrics.CountLabels("", "http_requests_unauthorized_total", "Total requests with failed authentication.",
+				labels, values)
+			return
+		}
+
+		AddRequestMeta(r, "entry.auth.sub", identity)
+
+		handler(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, identity)), p)
+	}
+}
+
+func parseCredentials(data io.Reader) (map[string]string, error) {
+	credentials := make(map[string]string)
+	scanner := bufio.NewScanner(data)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		identity, secret, found := strings.Cut(line, ":")
+		if !found {
+			return nil, errors.New("invalid credentials line, expected identity:secret")
+		}
+		credentials[identity] = secret
+	}
+	return credentials, scanner.Err()
+}
+
+// checkPassword compares the password with a bcrypt or SHA-1 hash in constant time.
+func checkPassword(hash, password string) bool {
+	if strings.HasPrefix(hash, shaPrefix) {
+		sum := sha1.Sum([]byte(password))
+		expected := shaPrefix + base64.StdEncoding.EncodeToString(sum[:])
+		return subtle.ConstantTimeCompare([]byte(hash), []byte(expected)) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+func getDummyBcryptHash() []byte {
+	dummyBcryptHashOnce.Do(func() {
+		dummyBcryptHash, _ = bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+	})
+	return dummyBcryptHash
+}
@@ -0,0 +1,128 @@
+package v8_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	sf "github.com/Travix-International/go-servicefoundation/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAPIKeyAuthenticator_Authenticate(t *testing.T) {
+	t.Setenv("SF_API_KEYS", "ci:secret-1, secret-2")
+	store, err := sf.NewCredentialStore(sf.CredentialsFromEnv("SF_API_KEYS"), 0)
+	sut := sf.NewAPIKeyAuthenticator("", store)
+
+	scenarios := []struct {
+		key              string
+		expectedIdentity string
+		expectedOk       bool
+	}{
+		{"secret-1", "ci", true},
+		{"secret-2", "key2", true},
+		{"secret-3", "", false},
+		{"", "", false},
+	}
+
+	for i, scenario := range scenarios {
+		r := httptest.NewRequest(http.MethodGet, "https://www.sf.com/metrics", nil)
+		r.Header.Set(sf.APIKeyHeader, scenario.key)
+
+		// Act
+		identity, ok := sut.Authenticate(r)
+
+		assert.Nil(t, err)
+		assert.Equal(t, scenario.expectedIdentity, identity, "Scenario %d", i)
+		assert.Equal(t, scenario.expectedOk, ok, "Scenario %d", i)
+	}
+}
+
+func TestBasicAuthenticator_Authenticate(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("bcrypt-pass"), bcrypt.MinCost)
+	path := filepath.Join(t.TempDir(), ".htpasswd")
+	os.WriteFile(path, []byte("# users\nalice:"+string(hash)+"\nbob:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=\n"), 0600)
+	store, err := sf.NewCredentialStore(sf.CredentialsFromFile(path), 0)
+	sut := sf.NewBasicAuthenticator("internal", store)
+
+	scenarios := []struct {
+		user       string
+		password   string
+		expectedOk bool
+	}{
+		{"alice", "bcrypt-pass", true},
+		{"bob", "password", true},
+		{"alice", "wrong", false},
+		{"carol", "password", false},
+	}
+
+	for i, scenario := range scenarios {
+		r := httptest.NewRequest(http.MethodGet, "https://www.sf.com/metrics", nil)
+		r.SetBasicAuth(scenario.user, scenario.password)
+
+		// Act
+		_, ok := sut.Authenticate(r)
+
+		assert.Nil(t, err)
+		assert.Equal(t, scenario.expectedOk, ok, "Scenario %d", i)
+	}
+	assert.Equal(t, `Basic realm="internal", charset="UTF-8"`, sut.Challenge())
+}
+
+func TestCredentialStore_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+	os.WriteFile(path, []byte("ci:secret-1\n"), 0600)
+	sut, _ := sf.NewCredentialStore(sf.CredentialsFromFile(path), time.Hour)
+	os.WriteFile(path, []byte("ci:secret-2\n"), 0600)
+
+	// Act
+	before := sut.Credentials()["ci"]
+	err := sut.Reload()
+	after := sut.Credentials()["ci"]
+	os.Remove(path)
+	failedErr := sut.Reload()
+
+	assert.Equal(t, "secret-1", before)
+	assert.Nil(t, err)
+	assert.Equal(t, "secret-2", after)
+	assert.NotNil(t, failedErr)
+	assert.Equal(t, "secret-2", sut.Credentials()["ci"])
+}
+
+func TestMiddlewareWrapperImpl_Wrap_Authentication(t *testing.T) {
+	logFactory := &mockLogFactory{}
+	log := &mockLogger{}
+	m := &mockMetrics{}
+	var actual string
+	handle := func(_ sf.WrappedResponseWriter, r *http.Request, _ sf.RouterParams) {
+		actual = sf.IdentityFromContext(r.Context())
+	}
+	authenticator := sf.AuthenticatorFunc(func(r *http.Request) (string, bool) {
+		return "ci", r.Header.Get("Authorization") == "let-me-in"
+	})
+
+	logFactory.On("NewLogger", mock.Anything).Return(log)
+	m.On("CountLabels", "", "http_requests_unauthorized_total", mock.Anything, mock.Anything, mock.Anything).Once()
+
+	sut := sf.NewMiddlewareWrapper(logFactory, m, &sf.CORSOptions{}, sf.ServiceGlobals{})
+	wrapped := sut.Wrap(sf.InternalSubsystem, "metrics", sf.Authentication(authenticator), handle, nil)
+
+	// Act
+	rejected := httptest.NewRecorder()
+	wrapped(sf.NewWrappedResponseWriter(rejected), httptest.NewRequest(http.MethodGet, "https://www.sf.com/metrics", nil),
+		sf.RouterParams{})
+	accepted := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "https://www.sf.com/metrics", nil)
+	r.Header.Set("Authorization", "let-me-in")
+	wrapped(sf.NewWrappedResponseWriter(accepted), r, sf.RouterParams{})
+
+	assert.Equal(t, http.StatusUnauthorized, rejected.Code)
+	assert.Equal(t, http.StatusOK, accepted.Code)
+	assert.Equal(t, "ci", actual)
+	m.AssertExpectations(t)
+}
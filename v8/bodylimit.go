@@ -0,0 +1,187 @@
+package v8
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const defaultMaxBodyBytes = 1 << 20
+
+type (
+	// BodyLimitOptions contains properties used by the BodyLimit middleware.
+	BodyLimitOptions struct {
+		// MaxBytes is the maximum size in bytes of a request body, as sent by the client. Default value is 1 MiB.
+		MaxBytes int64
+		// DecompressGzip transparently decompresses request bodies with Content-Encoding gzip.
+		DecompressGzip bool
+		// MaxDecompressedBytes is the maximum size in bytes of a decompressed request body. Default value is MaxBytes.
+		MaxDecompressedBytes int64
+	}
+
+	// bodyLimitState is shared by the reader and writer of a request to replace the response with http status-code
+	// 413 when the body turns out to be too large.
+	bodyLimitState struct {
+		exceeded bool
+		limit    int64
+		written  bool
+	}
+
+	bodyLimitReader struct {
+		io.ReadCloser
+		state *bodyLimitState
+	}
+
+	bodyLimitWriter struct {
+		WrappedResponseWriter
+		state *bodyLimitState
+	}
+
+	// decompressedLimitReader returns an *http.MaxBytesError when more than the limit is read.
+	decompressedLimitReader struct {
+		io.ReadCloser
+		remaining int64
+		limit     int64
+	}
+
+	gzipBody struct {
+		*gzip.Reader
+		body io.ReadCloser
+	}
+)
+
+// BodyLimit returns a Middleware enumeration that limits the size of request bodies. Requests that are too large get
+// http status-code 413, as long as the handler did not write a response before reading the whole body.
+func BodyLimit(options BodyLimitOptions) Middleware {
+	if options.MaxBytes <= 0 {
+		options.MaxBytes = defaultMaxBodyBytes
+	}
+	if options.MaxDecompressedBytes <= 0 {
+		options.MaxDecompressedBytes = options.MaxBytes
+	}
+
+	return registerMiddleware("", func(m *middlewareWrapperImpl, subsystem, name string, handler Handle,
+		metaFunc MetaFunc) Handle {
+
+		return m.wrapWithBodyLimit(subsystem, name, handler, metaFunc, options)
+	})
+}
+
+func (m *middlewareWrapperImpl) wrapWithBodyLimit(subsystem, name string, handler Handle, metaFunc MetaFunc,
+	options BodyLimitOptions) Handle {
+
+	return func(w WrappedResponseWriter, r *http.Request, p RouterParams) {
+		if r.ContentLength > options.MaxBytes {
+			m.rejectBodyTooLarge(subsystem, name, w, r, p, metaFunc, false, options.MaxBytes)
+			return
+		}
+
+		state := &bodyLimitState{}
+		var body io.ReadCloser = http.MaxBytesReader(w, r.Body, options.MaxBytes)
+
+		if options.DecompressGzip && strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+			gz, err := gzip.NewReader(&bodyLimitReader{ReadCloser: body, state: state})
+			if err != nil {
+				if state.exceeded {
+					m.rejectBodyTooLarge(subsystem, name, w, r, p, metaFunc, false, state.limit)
+					return
+				}
+				writeErrorResponse(w, r, http.StatusBadRequest, "Invalid gzip request body")
+				return
+			}
+
+			body = &decompressedLimitReader{
+				ReadCloser: &gzipBody{Reader: gz, body: body},
+				remaining:  options.MaxDecompressedBytes,
+				limit:      options.MaxDecompressedBytes,
+			}
+			r.Header.Del("Content-Encoding")
+			r.Header.Del("Content-Length")
+			r.ContentLength = -1
+		}
+
+		r.Body = &bodyLimitReader{ReadCloser: body, state: state}
+
+		handler(NewWrappedResponseWriter(&bodyLimitWriter{WrappedResponseWriter: w, state: state}), r, p)
+
+		if state.exceeded {
+			m.rejectBodyTooLarge(subsystem, name, w, r, p, metaFunc, state.written, state.limit)
+		}
+	}
+}
+
+// rejectBodyTooLarge counts and logs the oversized request, and responds with http status-code 413 unless a response
+// was written already.
+func (m *middlewareWrapperImpl) rejectBodyTooLarge(subsystem, name string, w WrappedResponseWriter, r *http.Request,
+	p RouterParams, metaFunc MetaFunc, written bool, limit int64) {
+
+	if !written {
+		writeErrorResponse(w, r, http.StatusRequestEntityTooLarge, "Request body too large")
+	}
+
+	labels, values := m.getLabelsAndValues(subsystem, name, w, r)
+	m.metrics.CountLabels("", "http_requests_body_too_large_total", "Total requests with a body exceeding the limit.",
+		labels, values)
+
+	log := m.getMetaLog(subsystem, name, w, r, p, metaFunc(r, p))
+	log.Warn("RequestBodyTooLarge", "Request body exceeds the limit of %d bytes", limit)
+}
+
+/* io.Reader implementation */
+
+func (b *bodyLimitReader) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+
+	var maxBytesErr *http.MaxBytesError
+	if err != nil && errors.As(err, &maxBytesErr) {
+		b.state.exceeded = true
+		b.state.limit = maxBytesErr.Limit
+	}
+	return n, err
+}
+
+func (d *decompressedLimitReader) Read(p []byte) (int, error) {
+	if d.remaining <= 0 {
+		// Check whether there is more data, so a body of exactly the limit is accepted.
+		var one [1]byte
+		if n, err := d.ReadCloser.Read(one[:]); n == 0 {
+			return 0, err
+		}
+		return 0, &http.MaxBytesError{Limit: d.limit}
+	}
+
+	if int64(len(p)) > d.remaining {
+		p = p[:d.remaining]
+	}
+	n, err := d.ReadCloser.Read(p)
+	d.remaining -= int64(n)
+	return n, err
+}
+
+func (g *gzipBody) Close() error {
+	g.Reader.Close()
+	return g.body.Close()
+}
+
+/* http.ResponseWriter implementation */
+
+func (b *bodyLimitWriter) WriteHeader(code int) {
+	if b.state.exceeded {
+		return
+	}
+	if code != http.StatusOK {
+		// Like the WrappedResponseWriter, status "OK" is not sent until the body is written.
+		b.state.written = true
+	}
+	b.WrappedResponseWriter.WriteHeader(code)
+}
+
+func (b *bodyLimitWriter) Write(p []byte) (int, error) {
+	if b.state.exceeded {
+		return len(p), nil
+	}
+	b.state.written = true
+	return b.WrappedResponseWriter.Write(p)
+}
@@ -0,0 +1,84 @@
+package v8_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	sf "github.com/Travix-International/go-servicefoundation/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func gzipped(s string) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	gw.Write([]byte(s))
+	gw.Close()
+	return buf.Bytes()
+}
+
+func TestMiddlewareWrapperImpl_Wrap_BodyLimit(t *testing.T) {
+	bomb := gzipped(strings.Repeat("a", 10000))
+
+	scenarios := []struct {
+		body            []byte
+		contentEncoding string
+		chunked         bool
+		expectedStatus  int
+		expectedBody    string
+	}{
+		{[]byte("small"), "", false, http.StatusOK, "small"},
+		{bytes.Repeat([]byte("a"), 200), "", false, http.StatusRequestEntityTooLarge, ""},
+		{bytes.Repeat([]byte("a"), 200), "", true, http.StatusRequestEntityTooLarge, ""},
+		{gzipped(strings.Repeat("b", 150)), "gzip", false, http.StatusOK, strings.Repeat("b", 150)},
+		{bomb, "gzip", false, http.StatusRequestEntityTooLarge, ""},
+		{[]byte("not gzip"), "gzip", false, http.StatusBadRequest, ""},
+	}
+
+	for i, scenario := range scenarios {
+		logFactory := &mockLogFactory{}
+		log := &mockLogger{}
+		m := &mockMetrics{}
+		var actual string
+		handle := func(w sf.WrappedResponseWriter, r *http.Request, _ sf.RouterParams) {
+			b, err := io.ReadAll(r.Body)
+			if err != nil {
+				w.WriteResponse(r, http.StatusBadRequest, sf.ErrorResponse{Message: err.Error()})
+				return
+			}
+			actual = string(b)
+		}
+		metaFunc := func(*http.Request, sf.RouterParams) map[string]string {
+			return make(map[string]string)
+		}
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "https://www.sf.com/some/url", bytes.NewReader(scenario.body))
+		r.Header.Set("Content-Encoding", scenario.contentEncoding)
+		if scenario.chunked {
+			r.ContentLength = -1
+		}
+
+		logFactory.On("NewLogger", mock.Anything).Return(log)
+		log.On("Warn", "RequestBodyTooLarge", mock.Anything, mock.Anything).Maybe()
+		m.On("CountLabels", "", "http_requests_body_too_large_total", mock.Anything, mock.Anything, mock.Anything).Maybe()
+
+		sut := sf.NewMiddlewareWrapper(logFactory, m, &sf.CORSOptions{}, sf.ServiceGlobals{})
+		middleware := sf.BodyLimit(sf.BodyLimitOptions{MaxBytes: 100, DecompressGzip: true, MaxDecompressedBytes: 150})
+
+		// Act
+		sut.Wrap("my-sub", "my-name", middleware, handle, metaFunc)(sf.NewWrappedResponseWriter(w), r, sf.RouterParams{})
+
+		assert.Equal(t, scenario.expectedStatus, w.Code, "Scenario %d", i)
+		assert.Equal(t, scenario.expectedBody, actual, "Scenario %d", i)
+		if scenario.expectedStatus == http.StatusRequestEntityTooLarge {
+			assert.Equal(t, "{\"Message\":\"Request body too large\"}\n", w.Body.String(), "Scenario %d", i)
+			m.AssertNumberOfCalls(t, "CountLabels", 1)
+			log.AssertNumberOfCalls(t, "Warn", 1)
+		}
+	}
+}
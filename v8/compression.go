@@ -0,0 +1,286 @@
+package v8
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	defaultCompressionMinSize = 1024
+	defaultBrotliLevel        = 4
+)
+
+// defaultCompressor is used by the Compression middleware.
+var defaultCompressor = newCompressor(CompressionOptions{})
+
+// defaultCompressibleTypes contains the content types compressed by default. Entries ending with a slash match all
+// subtypes.
+var defaultCompressibleTypes = []string{
+	"text/",
+	ContentTypeJSON,
+	ContentTypeXML,
+	"application/javascript",
+	"application/problem+json",
+	"image/svg+xml",
+}
+
+type (
+	// CompressionOptions contains properties used by the Compression middleware.
+	CompressionOptions struct {
+		// MinSize is the minimum size in bytes of a response to compress it. Default value is 1024.
+		MinSize int
+		// ContentTypes contains the content types to compress. Entries ending with a slash, like "text/", match all
+		// subtypes. Default value contains text, JSON, XML, JavaScript and SVG types.
+		ContentTypes []string
+		// GzipLevel is the gzip compression level. Default value is gzip.DefaultCompression.
+		GzipLevel int
+		// BrotliLevel is the brotli compression level. Default value is 4.
+		BrotliLevel int
+	}
+
+	compressor struct {
+		options     CompressionOptions
+		gzipPool    sync.Pool
+		brotliPool  sync.Pool
+		contentType map[string]bool
+	}
+
+	// compressWriter buffers the start of a response until it can decide whether to compress it.
+	compressWriter struct {
+		WrappedResponseWriter
+		compressor *compressor
+		encoding   string
+		status     int
+		buf        bytes.Buffer
+		decided    bool
+		encoder    io.WriteCloser
+	}
+)
+
+// CompressionWith returns a Middleware enumeration that compresses responses with gzip or brotli using the specified
+// options. The encoders are pooled and shared by all routes using the returned Middleware.
+func CompressionWith(options CompressionOptions) Middleware {
+	c := newCompressor(options)
+
+	return registerMiddleware("", func(m *middlewareWrapperImpl, subsystem, name string, handler Handle,
+		_ MetaFunc) Handle {
+
+		return c.wrap(handler)
+	})
+}
+
+func newCompressor(options CompressionOptions) *compressor {
+	if options.MinSize <= 0 {
+		options.MinSize = defaultCompressionMinSize
+	}
+	if options.ContentTypes == nil {
+		options.ContentTypes = defaultCompressibleTypes
+	}
+	if options.GzipLevel == 0 {
+		options.GzipLevel = gzip.DefaultCompression
+	}
+	if options.BrotliLevel == 0 {
+		options.BrotliLevel = defaultBrotliLevel
+	}
+
+	c := &compressor{options: options, contentType: make(map[string]bool)}
+	for _, t := range options.ContentTypes {
+		c.contentType[strings.ToLower(t)] = true
+	}
+
+	c.gzipPool.New = func() interface{} {
+		w, err := gzip.NewWriterLevel(io.Discard, options.GzipLevel)
+		if err != nil {
+			w = gzip.NewWriter(io.Discard)
+		}
+		return w
+	}
+	c.brotliPool.New = func() interface{} {
+		return brotli.NewWriterLevel(io.Discard, options.BrotliLevel)
+	}
+	return c
+}
+
+func (m *middlewareWrapperImpl) wrapWithCompression(handler Handle) Handle {
+	return defaultCompressor.wrap(handler)
+}
+
+func (c *compressor) wrap(handler Handle) Handle {
+	return func(w WrappedResponseWriter, r *http.Request, p RouterParams) {
+		addVaryHeader(w.Header(), "Accept-Encoding")
+
+		encoding := c.negotiate(r)
+		if encoding == "" {
+			handler(w, r, p)
+			return
+		}
+
+		cw := &compressWriter{WrappedResponseWriter: w, compressor: c, encoding: encoding, status: http.StatusOK}
+
+		// Not deferred: after a panic the buffered response is dropped, so PanicTo500 can still respond.
+		handler(NewWrappedResponseWriter(cw), r, p)
+		cw.close()
+	}
+}
+
+// negotiate returns the preferred encoding accepted by the client, or an empty string when the response should not
+// be compressed.
+func (c *compressor) negotiate(r *http.Request) string {
+	if r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+		return ""
+	}
+
+	accepted := r.Header.Get("Accept-Encoding")
+	for _, encoding := range []string{"br", "gzip"} {
+		if acceptsEncoding(accepted, encoding) {
+			return encoding
+		}
+	}
+	return ""
+}
+
+// compressible returns whether a response with the specified content type can be compressed.
+func (c *compressor) compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if c.contentType[mediaType] {
+		return true
+	}
+
+	if i := strings.Index(mediaType, "/"); i >= 0 {
+		return c.contentType[mediaType[:i+1]]
+	}
+	return false
+}
+
+func (c *compressor) newEncoder(encoding string, w io.Writer) io.WriteCloser {
+	if encoding == "br" {
+		bw := c.brotliPool.Get().(*brotli.Writer)
+		bw.Reset(w)
+		return bw
+	}
+
+	gw := c.gzipPool.Get().(*gzip.Writer)
+	gw.Reset(w)
+	return gw
+}
+
+func (c *compressor) releaseEncoder(encoder io.WriteCloser) {
+	switch e := encoder.(type) {
+	case *brotli.Writer:
+		e.Reset(io.Discard)
+		c.brotliPool.Put(e)
+	case *gzip.Writer:
+		e.Reset(io.Discard)
+		c.gzipPool.Put(e)
+	}
+}
+
+/* http.ResponseWriter implementation */
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.decided {
+		return
+	}
+	cw.status = code
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		cw.buf.Write(p)
+		if cw.buf.Len() < cw.compressor.options.MinSize {
+			return len(p), nil
+		}
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
+	if cw.encoder != nil {
+		return cw.encoder.Write(p)
+	}
+	return cw.WrappedResponseWriter.Write(p)
+}
+
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.decide(true)
+	}
+	if f, ok := cw.encoder.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := cw.WrappedResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// decide writes the header, choosing whether to compress the response, and the buffered start of the body.
+func (cw *compressWriter) decide(largeEnough bool) error {
+	cw.decided = true
+	h := cw.Header()
+
+	if largeEnough && cw.shouldCompress() {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", cw.encoding)
+
+		// The compressed representation differs byte-wise, so a strong validator no longer applies.
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		cw.encoder = cw.compressor.newEncoder(cw.encoding, cw.WrappedResponseWriter)
+	}
+
+	cw.WrappedResponseWriter.WriteHeader(cw.status)
+
+	if cw.buf.Len() == 0 {
+		return nil
+	}
+
+	var err error
+	if cw.encoder != nil {
+		_, err = cw.encoder.Write(cw.buf.Bytes())
+	} else {
+		_, err = cw.WrappedResponseWriter.Write(cw.buf.Bytes())
+	}
+	cw.buf.Reset()
+	return err
+}
+
+func (cw *compressWriter) shouldCompress() bool {
+	h := cw.Header()
+
+	if cw.status < http.StatusOK || cw.status == http.StatusNoContent || cw.status == http.StatusNotModified {
+		return false
+	}
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+
+	contentType := h.Get(ContentTypeHeader)
+	if contentType == "" {
+		contentType = http.DetectContentType(cw.buf.Bytes())
+	}
+	return cw.compressor.compressible(contentType)
+}
+
+// close writes the remaining buffered response and completes the compressed stream.
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		cw.decide(cw.buf.Len() >= cw.compressor.options.MinSize)
+	}
+	if cw.encoder != nil {
+		cw.encoder.Close()
+		cw.compressor.releaseEncoder(cw.encoder)
+		cw.encoder = nil
+	}
+}
@@ -0,0 +1,114 @@
+package v8_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	sf "github.com/Travix-International/go-servicefoundation/v8"
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func decompress(t *testing.T, encoding string, body []byte) string {
+	var reader io.Reader
+	switch encoding {
+	case "gzip":
+		gr, err := gzip.NewReader(bytes.NewReader(body))
+		assert.Nil(t, err)
+		reader = gr
+	case "br":
+		reader = brotli.NewReader(bytes.NewReader(body))
+	default:
+		reader = bytes.NewReader(body)
+	}
+
+	b, err := io.ReadAll(reader)
+	assert.Nil(t, err)
+	return string(b)
+}
+
+func TestMiddlewareWrapperImpl_Wrap_Compression(t *testing.T) {
+	large := strings.Repeat("hello world ", 200)
+
+	scenarios := []struct {
+		acceptEncoding   string
+		contentType      string
+		body             string
+		status           int
+		expectedEncoding string
+	}{
+		{"gzip, deflate", sf.ContentTypeJSON, large, http.StatusOK, "gzip"},
+		{"gzip, br", sf.ContentTypeJSON, large, http.StatusOK, "br"},
+		{"gzip, br;q=0", "text/html; charset=utf-8", large, http.StatusNotFound, "gzip"},
+		{"gzip", sf.ContentTypeJSON, "small", http.StatusOK, ""},
+		{"gzip", "image/png", large, http.StatusOK, ""},
+		{"", sf.ContentTypeJSON, large, http.StatusOK, ""},
+	}
+
+	for i, scenario := range scenarios {
+		logFactory := &mockLogFactory{}
+		log := &mockLogger{}
+		m := &mockMetrics{}
+		var innerStatus int
+		handle := func(w sf.WrappedResponseWriter, _ *http.Request, _ sf.RouterParams) {
+			w.SetCaching(60)
+			w.Header().Set(sf.ContentTypeHeader, scenario.contentType)
+			w.WriteHeader(scenario.status)
+			w.Write([]byte(scenario.body))
+			innerStatus = w.Status()
+		}
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "https://www.sf.com/some/url", nil)
+		r.Header.Set("Accept-Encoding", scenario.acceptEncoding)
+
+		logFactory.On("NewLogger", mock.Anything).Return(log)
+
+		sut := sf.NewMiddlewareWrapper(logFactory, m, &sf.CORSOptions{}, sf.ServiceGlobals{})
+		wrapped := sf.NewWrappedResponseWriter(w)
+
+		// Act
+		sut.Wrap("my-sub", "my-name", sf.Compression, handle, nil)(wrapped, r, sf.RouterParams{})
+
+		assert.Equal(t, scenario.status, w.Code, "Scenario %d", i)
+		assert.Equal(t, scenario.status, wrapped.Status(), "Scenario %d", i)
+		assert.Equal(t, scenario.status, innerStatus, "Scenario %d", i)
+		assert.Equal(t, scenario.expectedEncoding, w.Header().Get("Content-Encoding"), "Scenario %d", i)
+		assert.Equal(t, []string{"Accept-Encoding", "Accept, Origin"}, w.Header().Values("Vary"), "Scenario %d", i)
+		assert.Equal(t, scenario.body, decompress(t, scenario.expectedEncoding, w.Body.Bytes()), "Scenario %d", i)
+	}
+}
+
+func TestMiddlewareWrapperImpl_Wrap_CompressionWithFlush(t *testing.T) {
+	logFactory := &mockLogFactory{}
+	log := &mockLogger{}
+	m := &mockMetrics{}
+	w := httptest.NewRecorder()
+	var flushed string
+	handle := func(w sf.WrappedResponseWriter, _ *http.Request, _ sf.RouterParams) {
+		w.Header().Set(sf.ContentTypeHeader, "text/event-stream")
+		w.Write([]byte("data: first\n\n"))
+		w.(http.Flusher).Flush()
+		flushed = w.Header().Get("Content-Encoding")
+		w.Write([]byte("data: second\n\n"))
+	}
+	r := httptest.NewRequest(http.MethodGet, "https://www.sf.com/events", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	middleware := sf.CompressionWith(sf.CompressionOptions{MinSize: 4096, ContentTypes: []string{"text/event-stream"}})
+
+	logFactory.On("NewLogger", mock.Anything).Return(log)
+
+	sut := sf.NewMiddlewareWrapper(logFactory, m, &sf.CORSOptions{}, sf.ServiceGlobals{})
+
+	// Act
+	sut.Wrap("my-sub", "my-name", middleware, handle, nil)(sf.NewWrappedResponseWriter(w), r, sf.RouterParams{})
+
+	assert.True(t, w.Flushed)
+	assert.Equal(t, "gzip", flushed)
+	assert.Equal(t, "data: first\n\ndata: second\n\n", decompress(t, "gzip", w.Body.Bytes()))
+}
@@ -0,0 +1,258 @@
+package v8
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultConcurrencyLimitName = "default"
+	defaultMaxInFlight          = 100
+
+	// latencySmoothing is the weight of a new sample in the moving average latency used by adaptive limiters.
+	latencySmoothing = 0.1
+	// limitDecreaseFactor is the factor applied to the limit of an adaptive limiter when latency exceeds the target.
+	limitDecreaseFactor = 0.9
+)
+
+type (
+	// ConcurrencyLimitOptions contains properties used by NewConcurrencyLimiter.
+	ConcurrencyLimitOptions struct {
+		// Name is used in the names of the gauge metrics of the limiter. Default value is "default".
+		Name string
+		// MaxInFlight is the maximum number of requests handled at once. Default value is 100.
+		MaxInFlight int
+		// MaxQueued is the maximum number of requests waiting for a slot. When 0, excess requests are rejected
+		// immediately.
+		MaxQueued int
+		// MaxWait is the maximum time a request waits for a slot before it is rejected.
+		MaxWait time.Duration
+		// Adaptive lowers the limit while the average latency exceeds TargetLatency, and raises it again up to
+		// MaxInFlight when latency recovers. It also rejects requests immediately when the queue depth indicates
+		// they will not get a slot within MaxWait.
+		Adaptive bool
+		// TargetLatency is the average latency an adaptive limiter aims for.
+		TargetLatency time.Duration
+		// MinInFlight is the lowest limit of an adaptive limiter. Default value is 1.
+		MinInFlight int
+	}
+
+	// ConcurrencyLimiter is an interface for limiting the number of requests handled at once.
+	ConcurrencyLimiter interface {
+		// Acquire waits for a slot. It returns a function to release the slot, or false when the request is shed.
+		Acquire(ctx context.Context) (release func(), ok bool)
+		// InFlight returns the number of requests holding a slot.
+		InFlight() int
+		// Queued returns the number of requests waiting for a slot.
+		Queued() int
+		// Limit returns the current maximum number of requests handled at once.
+		Limit() int
+	}
+
+	concurrencyLimiterImpl struct {
+		mutex      sync.Mutex
+		options    ConcurrencyLimitOptions
+		limit      float64
+		inFlight   int
+		waiters    *list.List
+		avgLatency float64
+	}
+)
+
+// NewConcurrencyLimiter instantiates a new ConcurrencyLimiter implementation.
+func NewConcurrencyLimiter(options ConcurrencyLimitOptions) ConcurrencyLimiter {
+	if options.Name == "" {
+		options.Name = defaultConcurrencyLimitName
+	}
+	if options.MaxInFlight <= 0 {
+		options.MaxInFlight = defaultMaxInFlight
+	}
+	if options.MinInFlight <= 0 {
+		options.MinInFlight = 1
+	}
+	if options.MinInFlight > options.MaxInFlight {
+		options.MinInFlight = options.MaxInFlight
+	}
+
+	return &concurrencyLimiterImpl{
+		options: options,
+		limit:   float64(options.MaxInFlight),
+		waiters: list.New(),
+	}
+}
+
+// ConcurrencyLimit returns a Middleware enumeration that limits the number of requests handled at once using the
+// specified limiter. Shed requests get http status-code 503. Using the returned Middleware for multiple routes, or
+// as global middleware, shares the limit between them.
+func ConcurrencyLimit(limiter ConcurrencyLimiter) Middleware {
+	return registerMiddleware("", func(m *middlewareWrapperImpl, subsystem, name string, handler Handle,
+		_ MetaFunc) Handle {
+
+		return m.wrapWithConcurrencyLimit(subsystem, name, handler, limiter)
+	})
+}
+
+/* ConcurrencyLimiter implementation */
+
+func (l *concurrencyLimiterImpl) Acquire(ctx context.Context) (func(), bool) {
+	l.mutex.Lock()
+
+	if l.inFlight < int(l.limit) && l.waiters.Len() == 0 {
+		l.inFlight++
+		l.mutex.Unlock()
+		return l.releaser(), true
+	}
+
+	if l.waiters.Len() >= l.options.MaxQueued || l.options.MaxWait <= 0 || l.exceedsMaxWait() {
+		l.mutex.Unlock()
+		return nil, false
+	}
+
+	granted := make(chan struct{})
+	element := l.waiters.PushBack(granted)
+	l.mutex.Unlock()
+
+	timer := time.NewTimer(l.options.MaxWait)
+	defer timer.Stop()
+
+	select {
+	case <-granted:
+		return l.releaser(), true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// The slot may have been granted while the timer fired.
+	select {
+	case <-granted:
+		return l.releaser(), true
+	default:
+		l.waiters.Remove(element)
+		return nil, false
+	}
+}
+
+func (l *concurrencyLimiterImpl) InFlight() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.inFlight
+}
+
+func (l *concurrencyLimiterImpl) Queued() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.waiters.Len()
+}
+
+func (l *concurrencyLimiterImpl) Limit() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return int(l.limit)
+}
+
+// releaser returns a function that releases a slot acquired now. Calling it more than once has no effect.
+func (l *concurrencyLimiterImpl) releaser() func() {
+	start := time.Now()
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			l.release(time.Since(start))
+		})
+	}
+}
+
+func (l *concurrencyLimiterImpl) release(latency time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.inFlight--
+
+	if l.options.Adaptive {
+		l.adapt(latency)
+	}
+
+	for l.inFlight < int(l.limit) && l.waiters.Len() > 0 {
+		close(l.waiters.Remove(l.waiters.Front()).(chan struct{}))
+		l.inFlight++
+	}
+}
+
+// adapt updates the moving average latency and adjusts the limit: additive increase while the latency is on target,
+// multiplicative decrease otherwise.
+func (l *concurrencyLimiterImpl) adapt(latency time.Duration) {
+	if l.avgLatency == 0 {
+		l.avgLatency = float64(latency)
+	} else {
+		l.avgLatency += latencySmoothing * (float64(latency) - l.avgLatency)
+	}
+
+	if l.options.TargetLatency <= 0 {
+		return
+	}
+
+	if l.avgLatency > float64(l.options.TargetLatency) {
+		l.limit = math.Max(float64(l.options.MinInFlight), l.limit*limitDecreaseFactor)
+		return
+	}
+	l.limit = math.Min(float64(l.options.MaxInFlight), l.limit+1/l.limit)
+}
+
+// exceedsMaxWait returns whether an adaptive limiter expects a new request to wait longer than the maximum wait
+// time, based on the queue depth and average latency.
+func (l *concurrencyLimiterImpl) exceedsMaxWait() bool {
+	if !l.options.Adaptive || l.avgLatency == 0 {
+		return false
+	}
+
+	expectedWait := float64(l.waiters.Len()+1) * l.avgLatency / math.Floor(l.limit)
+	return expectedWait > float64(l.options.MaxWait)
+}
+
+func (m *middlewareWrapperImpl) wrapWithConcurrencyLimit(subsystem, name string, handler Handle,
+	limiter ConcurrencyLimiter) Handle {
+
+	limiterName := defaultConcurrencyLimitName
+	if l, ok := limiter.(*concurrencyLimiterImpl); ok {
+		limiterName = strings.ToLower(l.options.Name)
+	}
+	inFlightName := fmt.Sprintf("concurrency_%v_in_flight", limiterName)
+	queuedName := fmt.Sprintf("concurrency_%v_queued", limiterName)
+	limitName := fmt.Sprintf("concurrency_%v_limit", limiterName)
+
+	setGauges := func() {
+		m.metrics.SetGauge(float64(limiter.InFlight()), "", inFlightName, "Number of requests being handled.")
+		m.metrics.SetGauge(float64(limiter.Queued()), "", queuedName, "Number of requests waiting to be handled.")
+		m.metrics.SetGauge(float64(limiter.Limit()), "", limitName, "Maximum number of requests handled at once.")
+	}
+
+	return func(w WrappedResponseWriter, r *http.Request, p RouterParams) {
+		release, ok := limiter.Acquire(r.Context())
+		setGauges()
+
+		if !ok {
+			writeErrorResponse(w, r, http.StatusServiceUnavailable, "Service overloaded")
+
+			labels, values := m.getLabelsAndValues(subsystem, name, w, r)
+			m.metrics.CountLabels("", "http_requests_shed_total", "Total requests rejected by concurrency limiting.",
+				labels, values)
+			return
+		}
+
+		defer func() {
+			release()
+			setGauges()
+		}()
+
+		handler(w, r, p)
+	}
+}
@@ -0,0 +1,98 @@
+package v8_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sf "github.com/Travix-International/go-servicefoundation/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestConcurrencyLimiter_Acquire(t *testing.T) {
+	sut := sf.NewConcurrencyLimiter(sf.ConcurrencyLimitOptions{MaxInFlight: 1, MaxQueued: 1, MaxWait: time.Second})
+
+	// Act
+	release1, ok1 := sut.Acquire(context.Background())
+	acquired := make(chan bool)
+	go func() {
+		release2, ok2 := sut.Acquire(context.Background())
+		release2()
+		acquired <- ok2
+	}()
+	for sut.Queued() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	_, ok3 := sut.Acquire(context.Background())
+	release1()
+	ok2 := <-acquired
+
+	assert.True(t, ok1)
+	assert.True(t, ok2)
+	assert.False(t, ok3)
+	assert.Equal(t, 0, sut.InFlight())
+	assert.Equal(t, 0, sut.Queued())
+}
+
+func TestConcurrencyLimiter_Acquire_MaxWait(t *testing.T) {
+	sut := sf.NewConcurrencyLimiter(sf.ConcurrencyLimitOptions{MaxInFlight: 1, MaxQueued: 1,
+		MaxWait: 10 * time.Millisecond})
+	release, _ := sut.Acquire(context.Background())
+	defer release()
+
+	// Act
+	start := time.Now()
+	_, ok := sut.Acquire(context.Background())
+
+	assert.False(t, ok)
+	assert.True(t, time.Since(start) >= 10*time.Millisecond)
+	assert.Equal(t, 0, sut.Queued())
+}
+
+func TestConcurrencyLimiter_Adaptive(t *testing.T) {
+	sut := sf.NewConcurrencyLimiter(sf.ConcurrencyLimitOptions{MaxInFlight: 10, Adaptive: true,
+		TargetLatency: time.Millisecond})
+
+	// Act
+	release, _ := sut.Acquire(context.Background())
+	time.Sleep(5 * time.Millisecond)
+	release()
+
+	assert.Equal(t, 9, sut.Limit())
+}
+
+func TestMiddlewareWrapperImpl_Wrap_ConcurrencyLimit(t *testing.T) {
+	logFactory := &mockLogFactory{}
+	log := &mockLogger{}
+	m := &mockMetrics{}
+	limiter := sf.NewConcurrencyLimiter(sf.ConcurrencyLimitOptions{Name: "api", MaxInFlight: 1})
+	var shed *httptest.ResponseRecorder
+	var wrapped sf.Handle
+	handle := func(sf.WrappedResponseWriter, *http.Request, sf.RouterParams) {
+		shed = httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "https://www.sf.com/some/url", nil)
+		wrapped(sf.NewWrappedResponseWriter(shed), r, sf.RouterParams{})
+	}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "https://www.sf.com/some/url", nil)
+
+	logFactory.On("NewLogger", mock.Anything).Return(log)
+	m.On("SetGauge", mock.Anything, "", "concurrency_api_in_flight", mock.Anything)
+	m.On("SetGauge", mock.Anything, "", "concurrency_api_queued", mock.Anything)
+	m.On("SetGauge", mock.Anything, "", "concurrency_api_limit", mock.Anything)
+	m.On("CountLabels", "", "http_requests_shed_total", mock.Anything, mock.Anything, mock.Anything).Once()
+
+	sut := sf.NewMiddlewareWrapper(logFactory, m, &sf.CORSOptions{}, sf.ServiceGlobals{})
+	wrapped = sut.Wrap("my-sub", "my-name", sf.ConcurrencyLimit(limiter), handle, nil)
+
+	// Act
+	wrapped(sf.NewWrappedResponseWriter(w), r, sf.RouterParams{})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusServiceUnavailable, shed.Code)
+	assert.Equal(t, 0, limiter.InFlight())
+	m.AssertExpectations(t)
+}
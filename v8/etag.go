@@ -0,0 +1,149 @@
+package v8
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// ETagOptions contains properties used by the ETag middleware.
+	ETagOptions struct {
+		// Weak generates weak ETags, for responses that are semantically equal but not byte-for-byte identical.
+		Weak bool
+	}
+
+	// responseBuffer is an http.ResponseWriter that keeps the response in memory.
+	responseBuffer struct {
+		header http.Header
+		status int
+		body   bytes.Buffer
+	}
+)
+
+// ETagWith returns a Middleware enumeration that adds an ETag to GET and HEAD responses and answers conditional
+// requests, using the specified options. HEAD responses only get a generated ETag when the handler writes the body,
+// as it does for GET.
+func ETagWith(options ETagOptions) Middleware {
+	return registerMiddleware("", func(m *middlewareWrapperImpl, subsystem, name string, handler Handle,
+		_ MetaFunc) Handle {
+
+		return m.wrapWithETag(handler, options)
+	})
+}
+
+func (m *middlewareWrapperImpl) wrapWithETag(handler Handle, options ETagOptions) Handle {
+	return func(w WrappedResponseWriter, r *http.Request, p RouterParams) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			handler(w, r, p)
+			return
+		}
+
+		rb := newResponseBuffer(w.Header())
+		handler(NewWrappedResponseWriter(rb), r, p)
+
+		h := w.Header()
+		// Handlers that leave out the body of HEAD responses provide neither the ETag nor the Content-Length.
+		bodyless := r.Method == http.MethodHead && rb.body.Len() == 0
+
+		if rb.status == http.StatusOK {
+			etag := h.Get("ETag")
+			if etag == "" && !bodyless {
+				etag = computeETag(rb.body.Bytes(), options.Weak)
+				h.Set("ETag", etag)
+			}
+
+			if isNotModified(r, etag, h.Get("Last-Modified")) {
+				h.Del(ContentTypeHeader)
+				h.Del("Content-Length")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+
+		if bodyless {
+			w.WriteHeader(rb.status)
+			return
+		}
+		rb.writeTo(w)
+	}
+}
+
+func computeETag(body []byte, weak bool) string {
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	if weak {
+		return "W/" + etag
+	}
+	return etag
+}
+
+// isNotModified evaluates the If-None-Match and If-Modified-Since headers of a GET or HEAD request.
+func isNotModified(r *http.Request, etag, lastModified string) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatches(inm, etag)
+	}
+
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || lastModified == "" {
+		return false
+	}
+
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		return false
+	}
+	return !modified.Truncate(time.Second).After(since)
+}
+
+// etagMatches returns whether the specified If-None-Match header value matches the ETag, using weak comparison.
+func etagMatches(ifNoneMatch, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func newResponseBuffer(header http.Header) *responseBuffer {
+	return &responseBuffer{header: header, status: http.StatusOK}
+}
+
+/* http.ResponseWriter implementation */
+
+func (rb *responseBuffer) Header() http.Header {
+	return rb.header
+}
+
+func (rb *responseBuffer) WriteHeader(code int) {
+	rb.status = code
+}
+
+func (rb *responseBuffer) Write(p []byte) (int, error) {
+	return rb.body.Write(p)
+}
+
+// writeTo writes the buffered response, with a Content-Length header when absent.
+func (rb *responseBuffer) writeTo(w http.ResponseWriter) {
+	bodyAllowed := rb.status >= http.StatusOK && rb.status != http.StatusNoContent &&
+		rb.status != http.StatusNotModified
+
+	if bodyAllowed && w.Header().Get("Content-Length") == "" && w.Header().Get("Content-Encoding") == "" {
+		w.Header().Set("Content-Length", strconv.Itoa(rb.body.Len()))
+	}
+	w.WriteHeader(rb.status)
+	w.Write(rb.body.Bytes())
+}
@@ -0,0 +1,135 @@
+package v8_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	sf "github.com/Travix-International/go-servicefoundation/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMiddlewareWrapperImpl_Wrap_ETag(t *testing.T) {
+	const etag = `"e43abcf3375244839c012f9633f95862"`
+	const lastModified = "Wed, 21 Oct 2015 07:28:00 GMT"
+
+	scenarios := []struct {
+		method         string
+		header         string
+		value          string
+		handlerETag    string
+		status         int
+		expectedStatus int
+		expectedETag   string
+		expectedBody   string
+	}{
+		{http.MethodGet, "", "", "", http.StatusOK, http.StatusOK, etag, `{"key":"value"}`},
+		{http.MethodGet, "If-None-Match", etag, "", http.StatusOK, http.StatusNotModified, etag, ""},
+		{http.MethodHead, "If-None-Match", `"other", W/` + etag, "", http.StatusOK, http.StatusNotModified, etag, ""},
+		{http.MethodGet, "If-None-Match", `"other"`, "", http.StatusOK, http.StatusOK, etag, `{"key":"value"}`},
+		{http.MethodGet, "If-None-Match", `"v1"`, `"v1"`, http.StatusOK, http.StatusNotModified, `"v1"`, ""},
+		{http.MethodGet, "If-Modified-Since", lastModified, "", http.StatusOK, http.StatusNotModified, etag, ""},
+		{http.MethodGet, "If-Modified-Since", "Tue, 20 Oct 2015 07:28:00 GMT", "", http.StatusOK, http.StatusOK, etag,
+			`{"key":"value"}`},
+		{http.MethodGet, "If-None-Match", etag, "", http.StatusNotFound, http.StatusNotFound, "", `{"key":"value"}`},
+		{http.MethodPost, "If-None-Match", etag, "", http.StatusOK, http.StatusOK, "", `{"key":"value"}`},
+	}
+
+	for i, scenario := range scenarios {
+		logFactory := &mockLogFactory{}
+		log := &mockLogger{}
+		m := &mockMetrics{}
+		handle := func(w sf.WrappedResponseWriter, _ *http.Request, _ sf.RouterParams) {
+			w.SetCaching(60)
+			w.Header().Set("Last-Modified", lastModified)
+			if scenario.handlerETag != "" {
+				w.Header().Set("ETag", scenario.handlerETag)
+			}
+			w.Header().Set(sf.ContentTypeHeader, sf.ContentTypeJSON)
+			w.WriteHeader(scenario.status)
+			w.Write([]byte(`{"key":"value"}`))
+		}
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(scenario.method, "https://www.sf.com/config", nil)
+		r.Header.Set(scenario.header, scenario.value)
+
+		logFactory.On("NewLogger", mock.Anything).Return(log)
+
+		sut := sf.NewMiddlewareWrapper(logFactory, m, &sf.CORSOptions{}, sf.ServiceGlobals{})
+
+		// Act
+		sut.Wrap("my-sub", "my-name", sf.ETag, handle, nil)(sf.NewWrappedResponseWriter(w), r, sf.RouterParams{})
+
+		assert.Equal(t, scenario.expectedStatus, w.Code, "Scenario %d", i)
+		assert.Equal(t, scenario.expectedETag, w.Header().Get("ETag"), "Scenario %d", i)
+		assert.Equal(t, scenario.expectedBody, w.Body.String(), "Scenario %d", i)
+		assert.Equal(t, "public, max-age=60", w.Header().Get("Cache-Control"), "Scenario %d", i)
+	}
+}
+
+func TestMiddlewareWrapperImpl_Wrap_ETagWith_Weak(t *testing.T) {
+	logFactory := &mockLogFactory{}
+	log := &mockLogger{}
+	m := &mockMetrics{}
+	handle := func(w sf.WrappedResponseWriter, _ *http.Request, _ sf.RouterParams) {
+		w.Write([]byte(`{"key":"value"}`))
+	}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "https://www.sf.com/config", nil)
+
+	logFactory.On("NewLogger", mock.Anything).Return(log)
+
+	sut := sf.NewMiddlewareWrapper(logFactory, m, &sf.CORSOptions{}, sf.ServiceGlobals{})
+
+	// Act
+	sut.Wrap("my-sub", "my-name", sf.ETagWith(sf.ETagOptions{Weak: true}), handle, nil)(sf.NewWrappedResponseWriter(w),
+		r, sf.RouterParams{})
+
+	assert.Equal(t, `W/"e43abcf3375244839c012f9633f95862"`, w.Header().Get("ETag"))
+	assert.Equal(t, "15", w.Header().Get("Content-Length"))
+}
+
+func TestMiddlewareWrapperImpl_Wrap_ETagHead(t *testing.T) {
+	const etag = `"e43abcf3375244839c012f9633f95862"`
+
+	scenarios := []struct {
+		writeBody             bool
+		ifNoneMatch           string
+		expectedStatus        int
+		expectedETag          string
+		expectedContentLength string
+	}{
+		{true, "", http.StatusOK, etag, "15"},
+		{true, etag, http.StatusNotModified, etag, ""},
+		{false, "", http.StatusOK, "", ""},
+		{false, etag, http.StatusOK, "", ""},
+	}
+
+	for i, scenario := range scenarios {
+		logFactory := &mockLogFactory{}
+		log := &mockLogger{}
+		handle := func(w sf.WrappedResponseWriter, _ *http.Request, _ sf.RouterParams) {
+			w.Header().Set(sf.ContentTypeHeader, sf.ContentTypeJSON)
+			if scenario.writeBody {
+				w.Write([]byte(`{"key":"value"}`))
+			}
+		}
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodHead, "https://www.sf.com/config", nil)
+		if scenario.ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", scenario.ifNoneMatch)
+		}
+
+		logFactory.On("NewLogger", mock.Anything).Return(log)
+
+		sut := sf.NewMiddlewareWrapper(logFactory, &mockMetrics{}, &sf.CORSOptions{}, sf.ServiceGlobals{})
+
+		// Act
+		sut.Wrap("my-sub", "my-name", sf.ETag, handle, nil)(sf.NewWrappedResponseWriter(w), r, sf.RouterParams{})
+
+		assert.Equal(t, scenario.expectedStatus, w.Code, "Scenario %d", i)
+		assert.Equal(t, scenario.expectedETag, w.Header().Get("ETag"), "Scenario %d", i)
+		assert.Equal(t, scenario.expectedContentLength, w.Header().Get("Content-Length"), "Scenario %d", i)
+	}
+}
@@ -3,7 +3,6 @@ package v8
 import (
 	"net/http"
 
-	"github.com/julienschmidt/httprouter"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
@@ -13,7 +12,7 @@ type (
 
 	// WrapHandler is an interface for wrapping a Handle with middleware.
 	WrapHandler interface {
-		Wrap(string, string, []Middleware, Handle, MetaFunc) httprouter.Handle
+		Wrap(string, string, []Middleware, Handle, MetaFunc) RouterHandle
 	}
 
 	// RootHandler is an interface to instantiate a new root handler.
@@ -96,17 +95,26 @@ func NewServiceHandlerFactory(middlewareWrapper MiddlewareWrapper, versionBuilde
 
 /* ServiceHandlerFactory implementation */
 
-// Wrap wraps the specified Handle with the specified middleware wrappers.
+// Wrap wraps the specified Handle with the specified middleware wrappers. The middleware chain is built once, so
+// handling a request only executes the chain.
 func (f *serviceHandlerFactoryImpl) Wrap(subsystem, name string, middlewares []Middleware, handle Handle,
-	metaFunc MetaFunc) httprouter.Handle {
+	metaFunc MetaFunc) RouterHandle {
 
-	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
-		h := handle
+	h := handle
+	requestMeta := false
 
-		for i := 0; i < len(middlewares); i++ {
-			h = f.middlewareWrapper.Wrap(subsystem, name, middlewares[i], h, metaFunc)
+	for i := 0; i < len(middlewares); i++ {
+		h = f.middlewareWrapper.Wrap(subsystem, name, middlewares[i], h, metaFunc)
+		requestMeta = requestMeta || usesRequestMeta(middlewares[i])
+	}
+
+	if !requestMeta {
+		return func(w http.ResponseWriter, r *http.Request, p RouterParams) {
+			h(NewWrappedResponseWriter(w), r, p)
 		}
-		h(NewWrappedResponseWriter(w), r, RouterParams{Params: p})
+	}
+	return func(w http.ResponseWriter, r *http.Request, p RouterParams) {
+		h(NewWrappedResponseWriter(w), withRequestMeta(r), p)
 	}
 }
 
@@ -2,10 +2,10 @@ package v8_test
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	sf "github.com/Travix-International/go-servicefoundation/v8"
-	"github.com/julienschmidt/httprouter"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -241,7 +241,94 @@ func TestServiceHandlerFactoryImpl_WrapHandler(t *testing.T) {
 
 	// Act
 	actual := sut.Wrap(subSystem, name, []sf.Middleware{sf.CORS, sf.NoCaching}, handle, metaFunc)
-	actual(w, r, httprouter.Params{})
+	actual(w, r, sf.RouterParams{})
 
 	assert.True(t, handlerCalled)
 }
+
+type benchmarkResponseWriter struct {
+	header http.Header
+}
+
+func (w *benchmarkResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *benchmarkResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *benchmarkResponseWriter) WriteHeader(int) {
+}
+
+func BenchmarkServiceHandlerFactoryImpl_Wrap(b *testing.B) {
+	logFactory := &mockLogFactory{}
+	log := &mockLogger{}
+	m := &mockMetrics{}
+	corsOptions := &sf.CORSOptions{AllowedOrigins: []string{"*"}}
+	handle := func(w sf.WrappedResponseWriter, _ *http.Request, _ sf.RouterParams) {
+		w.WriteHeader(http.StatusOK)
+	}
+	metaFunc := func(*http.Request, sf.RouterParams) map[string]string {
+		return make(map[string]string)
+	}
+	w := &benchmarkResponseWriter{header: http.Header{}}
+	r, _ := http.NewRequest("GET", "https://www.sf.com/some/url", nil)
+	r.Header.Set("Origin", "https://www.sf.com")
+
+	logFactory.On("NewLogger", mock.Anything).Return(log)
+
+	mw := sf.NewMiddlewareWrapper(logFactory, m, corsOptions, sf.ServiceGlobals{})
+	sut := sf.NewServiceHandlerFactory(mw, &mockVersionBuilder{}, &mockServiceStateReader{}, func(int) {})
+	actual := sut.Wrap("my-sub", "my-name", []sf.Middleware{sf.PanicTo500, sf.CORS, sf.NoCaching}, handle, metaFunc)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for key := range w.header {
+			delete(w.header, key)
+		}
+		actual(w, r, sf.RouterParams{})
+	}
+}
+
+func TestServiceHandlerFactoryImpl_Wrap_RequestMetaOnlyWhenUsed(t *testing.T) {
+	scenarios := []struct {
+		middlewares []sf.Middleware
+		expected    string
+	}{
+		{[]sf.Middleware{sf.PanicTo500}, ""},
+		{[]sf.Middleware{sf.RequestID, sf.PanicTo500}, "value"},
+		{[]sf.Middleware{sf.RequestLoggingWith(sf.RequestLoggingOptions{}), sf.PanicTo500}, "value"},
+	}
+
+	for i, scenario := range scenarios {
+		logFactory := &mockLogFactory{}
+		log := &mockLogger{}
+		handle := func(_ sf.WrappedResponseWriter, r *http.Request, _ sf.RouterParams) {
+			sf.AddRequestMeta(r, "entry.custom", "value")
+			panic("whoa")
+		}
+		metaFunc := func(*http.Request, sf.RouterParams) map[string]string {
+			return make(map[string]string)
+		}
+		var actual string
+
+		logFactory.On("NewLogger", mock.Anything).Run(func(args mock.Arguments) {
+			actual = args.Get(0).(map[string]string)["entry.custom"]
+		}).Return(log)
+		log.On("Info", mock.Anything, mock.Anything, mock.Anything).Maybe()
+		log.On("Error", "PanicAutorecover", mock.Anything, mock.Anything).Once()
+
+		mw := sf.NewMiddlewareWrapper(logFactory, &mockMetrics{}, &sf.CORSOptions{}, sf.ServiceGlobals{})
+		sut := sf.NewServiceHandlerFactory(mw, &mockVersionBuilder{}, &mockServiceStateReader{}, func(int) {})
+
+		// Act
+		sut.Wrap("my-sub", "my-name", scenario.middlewares, handle, metaFunc)(httptest.NewRecorder(),
+			httptest.NewRequest(http.MethodGet, "/some/url", nil), sf.RouterParams{})
+
+		assert.Equal(t, scenario.expected, actual, "Scenario %d", i)
+		log.AssertExpectations(t)
+	}
+}
@@ -0,0 +1,311 @@
+package v8
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// IdempotencyKeyHeader is the name of the http header containing the idempotency key of a request.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is the name of the http header indicating a response is a replay of a stored response.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	defaultIdempotencyTTL        = 24 * time.Hour
+	defaultIdempotencyMaxEntries = 10000
+	maxIdempotencyKeyLength      = 255
+	idempotencySweepInterval     = time.Minute
+)
+
+type (
+	// IdempotencyRecord contains the state of a request with an idempotency key, and its response when completed.
+	IdempotencyRecord struct {
+		// Fingerprint identifies the method, path and body of the request.
+		Fingerprint string
+		// InFlight indicates the first request with the key has not completed yet.
+		InFlight bool
+		Status   int
+		Header   http.Header
+		Body     []byte
+	}
+
+	// IdempotencyStore is an interface for storing the responses of requests with an idempotency key.
+	IdempotencyStore interface {
+		// Begin reserves the key for a request with the specified fingerprint and returns true, or returns the
+		// existing record and false when the key is already in use.
+		Begin(key, fingerprint string) (IdempotencyRecord, bool)
+		// Complete stores the response for the reserved key.
+		Complete(key string, record IdempotencyRecord)
+		// Release removes the reservation of the key, so the request can be retried.
+		Release(key string)
+	}
+
+	// IdempotencyOptions contains properties used by the Idempotency middleware.
+	IdempotencyOptions struct {
+		// Store contains the responses. Default value is an in-memory store keeping up to 10000 responses for 24
+		// hours.
+		Store IdempotencyStore
+		// Methods contains the http methods that use idempotency keys. Default value is POST and PATCH.
+		Methods []string
+		// Required rejects requests without an idempotency key with http status-code 400.
+		Required bool
+		// MaxBodyBytes is the maximum size in bytes of the request body, which is read to fingerprint the request.
+		// Larger requests with an idempotency key get http status-code 413. Default value is 1 MiB.
+		MaxBodyBytes int64
+	}
+
+	memoryIdempotencyStore struct {
+		mutex      sync.Mutex
+		records    map[string]*list.Element
+		lru        *list.List
+		maxEntries int
+		ttl        time.Duration
+		swept      time.Time
+	}
+
+	idempotencyEntry struct {
+		key     string
+		record  IdempotencyRecord
+		expires time.Time
+	}
+)
+
+// Idempotency returns a Middleware enumeration that stores the first response for each Idempotency-Key header and
+// replays it for retries with the same key. Retries with a different method, path or body get http status-code 422,
+// and retries while the first request is in progress get 409. Server errors are not stored, so they can be retried.
+// Keys are scoped per route and per authenticated identity, from the Authentication middleware or the subject of the
+// JWT from the Auth middleware. Keys of anonymous requests are scoped per client IP, as resolved by the RealIP
+// middleware. Routes using the returned Middleware share the store.
+func Idempotency(options IdempotencyOptions) Middleware {
+	if options.MaxBodyBytes <= 0 {
+		options.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	if options.Store == nil {
+		options.Store = NewMemoryIdempotencyStore(defaultIdempotencyMaxEntries, defaultIdempotencyTTL)
+	}
+	if options.Methods == nil {
+		options.Methods = []string{http.MethodPost, http.MethodPatch}
+	}
+
+	methods := make(map[string]bool)
+	for _, method := range options.Methods {
+		methods[method] = true
+	}
+
+	return registerMiddleware("", func(m *middlewareWrapperImpl, subsystem, name string, handler Handle,
+		_ MetaFunc) Handle {
+
+		return m.wrapWithIdempotency(subsystem, name, handler, options, methods)
+	})
+}
+
+// NewMemoryIdempotencyStore instantiates a new in-memory IdempotencyStore implementation, which keeps records for the
+// specified time. It holds up to maxEntries records, evicting the least recently used ones.
+func NewMemoryIdempotencyStore(maxEntries int, ttl time.Duration) IdempotencyStore {
+	if maxEntries <= 0 {
+		maxEntries = defaultIdempotencyMaxEntries
+	}
+	if ttl <= 0 {
+		ttl = defaultIdempotencyTTL
+	}
+	return &memoryIdempotencyStore{
+		records:    make(map[string]*list.Element),
+		lru:        list.New(),
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		swept:      time.Now(),
+	}
+}
+
+/* IdempotencyStore implementation */
+
+func (s *memoryIdempotencyStore) Begin(key, fingerprint string) (IdempotencyRecord, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	if element, exists := s.records[key]; exists {
+		if entry := element.Value.(*idempotencyEntry); now.Before(entry.expires) {
+			s.lru.MoveToFront(element)
+			return entry.record, false
+		}
+	}
+
+	s.set(key, IdempotencyRecord{Fingerprint: fingerprint, InFlight: true}, now)
+	return IdempotencyRecord{}, true
+}
+
+func (s *memoryIdempotencyStore) Complete(key string, record IdempotencyRecord) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	record.InFlight = false
+	s.set(key, record, time.Now())
+}
+
+func (s *memoryIdempotencyStore) Release(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if element, exists := s.records[key]; exists {
+		s.remove(element)
+	}
+}
+
+// set stores the record as the most recently used one and evicts the least recently used records over the maximum.
+func (s *memoryIdempotencyStore) set(key string, record IdempotencyRecord, now time.Time) {
+	entry := &idempotencyEntry{key: key, record: record, expires: now.Add(s.ttl)}
+
+	if element, exists := s.records[key]; exists {
+		element.Value = entry
+		s.lru.MoveToFront(element)
+		return
+	}
+
+	s.records[key] = s.lru.PushFront(entry)
+	for s.lru.Len() > s.maxEntries {
+		s.remove(s.lru.Back())
+	}
+}
+
+func (s *memoryIdempotencyStore) remove(element *list.Element) {
+	s.lru.Remove(element)
+	delete(s.records, element.Value.(*idempotencyEntry).key)
+}
+
+// sweep removes the expired records, at most once per sweep interval.
+func (s *memoryIdempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.swept) < idempotencySweepInterval {
+		return
+	}
+	s.swept = now
+
+	for _, element := range s.records {
+		if !now.Before(element.Value.(*idempotencyEntry).expires) {
+			s.remove(element)
+		}
+	}
+}
+
+func (m *middlewareWrapperImpl) wrapWithIdempotency(subsystem, name string, handler Handle,
+	options IdempotencyOptions, methods map[string]bool) Handle {
+
+	return func(w WrappedResponseWriter, r *http.Request, p RouterParams) {
+		if !methods[r.Method] {
+			handler(w, r, p)
+			return
+		}
+
+		idempotencyKey := r.Header.Get(IdempotencyKeyHeader)
+		if idempotencyKey == "" {
+			if options.Required {
+				writeErrorResponse(w, r, http.StatusBadRequest, "Missing "+IdempotencyKeyHeader+" header")
+				return
+			}
+			handler(w, r, p)
+			return
+		}
+		if len(idempotencyKey) > maxIdempotencyKeyLength {
+			writeErrorResponse(w, r, http.StatusBadRequest, "Invalid "+IdempotencyKeyHeader+" header")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, options.MaxBodyBytes))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				writeErrorResponse(w, r, http.StatusRequestEntityTooLarge, "Request body too large")
+				return
+			}
+			writeErrorResponse(w, r, http.StatusBadRequest, "Unable to read request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		key := subsystem + "/" + name + "/" + idempotencyScope(r) + "/" + idempotencyKey
+		fingerprint := requestFingerprint(r, body)
+
+		record, ok := options.Store.Begin(key, fingerprint)
+		if !ok {
+			switch {
+			case record.Fingerprint != fingerprint:
+				writeErrorResponse(w, r, http.StatusUnprocessableEntity,
+					"Idempotency key was used for a different request")
+			case record.InFlight:
+				writeErrorResponse(w, r, http.StatusConflict, "Request with this idempotency key is in progress")
+			default:
+				writeIdempotencyRecord(w, record)
+			}
+			return
+		}
+
+		completed := false
+		defer func() {
+			// Also runs when the handler panics, so the request can be retried.
+			if !completed {
+				options.Store.Release(key)
+			}
+		}()
+
+		rb := newResponseBuffer(make(http.Header))
+		handler(NewWrappedResponseWriter(rb), r, p)
+
+		if rb.status < http.StatusInternalServerError {
+			options.Store.Complete(key, IdempotencyRecord{
+				Fingerprint: fingerprint,
+				Status:      rb.status,
+				Header:      rb.header.Clone(),
+				Body:        rb.body.Bytes(),
+			})
+			completed = true
+		}
+
+		h := w.Header()
+		for header, values := range rb.header {
+			h[header] = values
+		}
+		rb.writeTo(w)
+	}
+}
+
+// idempotencyScope returns the authenticated identity of the request, or the client IP of anonymous requests, so
+// clients can not replay each other's responses.
+func idempotencyScope(r *http.Request) string {
+	scope := IdentityFromContext(r.Context())
+	if claims, ok := ClaimsFromContext(r.Context()); ok {
+		scope += "/jwt:" + claims.Subject()
+	}
+	if scope == "" {
+		scope = "ip:" + ClientIP(r)
+	}
+	return scope
+}
+
+// requestFingerprint returns a hash of the method, path and body of the request.
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// writeIdempotencyRecord replays the stored response.
+func writeIdempotencyRecord(w WrappedResponseWriter, record IdempotencyRecord) {
+	h := w.Header()
+	for key, values := range record.Header {
+		h[key] = append([]string(nil), values...)
+	}
+	h.Set(IdempotentReplayedHeader, "true")
+
+	w.WriteHeader(record.Status)
+	w.Write(record.Body)
+}
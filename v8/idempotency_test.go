@@ -0,0 +1,268 @@
+package v8_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sf "github.com/Travix-International/go-servicefoundation/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMiddlewareWrapperImpl_Wrap_Idempotency(t *testing.T) {
+	scenarios := []struct {
+		options        sf.IdempotencyOptions
+		method         string
+		firstKey       string
+		secondKey      string
+		secondBody     string
+		firstStatus    int
+		expectedStatus int
+		expectedBody   string
+		expectedReplay string
+		expectedCalls  int32
+	}{
+		{sf.IdempotencyOptions{}, http.MethodPost, "key-1", "key-1", `{"amount":10}`, http.StatusCreated,
+			http.StatusCreated, `{"call":1}`, "true", 1},
+		{sf.IdempotencyOptions{}, http.MethodPost, "key-1", "key-2", `{"amount":10}`, http.StatusCreated,
+			http.StatusCreated, `{"call":2}`, "", 2},
+		{sf.IdempotencyOptions{}, http.MethodPost, "key-1", "key-1", `{"amount":20}`, http.StatusCreated,
+			http.StatusUnprocessableEntity, `{"Message":"Idempotency key was used for a different request"}` + "\n", "",
+			1},
+		{sf.IdempotencyOptions{}, http.MethodPost, "key-1", "key-1", `{"amount":10}`, http.StatusBadRequest,
+			http.StatusBadRequest, `{"call":1}`, "true", 1},
+		{sf.IdempotencyOptions{}, http.MethodPost, "key-1", "key-1", `{"amount":10}`, http.StatusBadGateway,
+			http.StatusBadGateway, `{"call":2}`, "", 2},
+		{sf.IdempotencyOptions{}, http.MethodPost, "", "", `{"amount":10}`, http.StatusCreated, http.StatusCreated,
+			`{"call":2}`, "", 2},
+		{sf.IdempotencyOptions{}, http.MethodPut, "key-1", "key-1", `{"amount":10}`, http.StatusCreated,
+			http.StatusCreated, `{"call":2}`, "", 2},
+		{sf.IdempotencyOptions{Required: true}, http.MethodPost, "key-1", "", `{"amount":10}`, http.StatusCreated,
+			http.StatusBadRequest, `{"Message":"Missing Idempotency-Key header"}` + "\n", "", 1},
+		{sf.IdempotencyOptions{}, http.MethodPost, "key-1", strings.Repeat("k", 256), `{"amount":10}`,
+			http.StatusCreated, http.StatusBadRequest, `{"Message":"Invalid Idempotency-Key header"}` + "\n", "", 1},
+		{sf.IdempotencyOptions{MaxBodyBytes: 5}, http.MethodPost, "key-1", "key-1", `{"amount":10}`,
+			http.StatusCreated, http.StatusRequestEntityTooLarge, `{"Message":"Request body too large"}` + "\n", "", 0},
+	}
+
+	for i, scenario := range scenarios {
+		logFactory := &mockLogFactory{}
+		log := &mockLogger{}
+		m := &mockMetrics{}
+		var calls int32
+		handle := func(w sf.WrappedResponseWriter, r *http.Request, _ sf.RouterParams) {
+			n := atomic.AddInt32(&calls, 1)
+			w.Header().Set("Location", "/payments/1")
+			w.Header().Set(sf.ContentTypeHeader, sf.ContentTypeJSON)
+			w.WriteHeader(scenario.firstStatus)
+			w.Write([]byte(`{"call":` + string(rune('0'+n)) + `}`))
+		}
+
+		logFactory.On("NewLogger", mock.Anything).Return(log)
+
+		sut := sf.NewMiddlewareWrapper(logFactory, m, &sf.CORSOptions{}, sf.ServiceGlobals{})
+		wrapped := sut.Wrap("my-sub", "my-name", sf.Idempotency(scenario.options), handle, nil)
+
+		serve := func(key, body string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(scenario.method, "https://www.sf.com/payments", strings.NewReader(body))
+			r.Header.Set("Accept", sf.ContentTypeJSON)
+			r.Header.Set(sf.IdempotencyKeyHeader, key)
+			wrapped(sf.NewWrappedResponseWriter(w), r, sf.RouterParams{})
+			return w
+		}
+
+		// Act
+		serve(scenario.firstKey, `{"amount":10}`)
+		w := serve(scenario.secondKey, scenario.secondBody)
+
+		assert.Equal(t, scenario.expectedStatus, w.Code, "Scenario %d", i)
+		assert.Equal(t, scenario.expectedBody, w.Body.String(), "Scenario %d", i)
+		assert.Equal(t, scenario.expectedReplay, w.Header().Get(sf.IdempotentReplayedHeader), "Scenario %d", i)
+		assert.Equal(t, scenario.expectedCalls, atomic.LoadInt32(&calls), "Scenario %d", i)
+		if scenario.expectedReplay != "" {
+			assert.Equal(t, "/payments/1", w.Header().Get("Location"), "Scenario %d", i)
+		}
+	}
+}
+
+func TestMiddlewareWrapperImpl_Wrap_IdempotencyScopedByJWTSubject(t *testing.T) {
+	keys := newJWTTestKeys(t)
+	logFactory := &mockLogFactory{}
+	log := &mockLogger{}
+	var calls int32
+	handle := func(w sf.WrappedResponseWriter, _ *http.Request, _ sf.RouterParams) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusCreated)
+	}
+	metaFunc := func(*http.Request, sf.RouterParams) map[string]string {
+		return make(map[string]string)
+	}
+
+	logFactory.On("NewLogger", mock.Anything).Return(log)
+
+	wrapper := sf.NewMiddlewareWrapper(logFactory, &mockMetrics{}, &sf.CORSOptions{}, sf.ServiceGlobals{})
+	sut := sf.NewServiceHandlerFactory(wrapper, nil, nil, nil)
+	middlewares := []sf.Middleware{sf.Idempotency(sf.IdempotencyOptions{}), sf.Auth(sf.JWTOptions{KeySet: keys.keySet})}
+	wrapped := sut.Wrap("my-sub", "my-name", middlewares, handle, metaFunc)
+
+	serve := func(subject string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "https://www.sf.com/payments", strings.NewReader(`{"amount":10}`))
+		r.Header.Set("Authorization", "Bearer "+keys.sign("RS256", "rsa", map[string]interface{}{"sub": subject}))
+		r.Header.Set(sf.IdempotencyKeyHeader, "key-1")
+		wrapped(w, r, sf.RouterParams{})
+		return w
+	}
+
+	// Act
+	serve("user-1")
+	other := serve("user-2")
+	replayed := serve("user-1")
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	assert.Equal(t, "", other.Header().Get(sf.IdempotentReplayedHeader))
+	assert.Equal(t, "true", replayed.Header().Get(sf.IdempotentReplayedHeader))
+}
+
+func TestMiddlewareWrapperImpl_Wrap_IdempotencyScopedByClientIP(t *testing.T) {
+	logFactory := &mockLogFactory{}
+	log := &mockLogger{}
+	var calls int32
+	handle := func(w sf.WrappedResponseWriter, _ *http.Request, _ sf.RouterParams) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusCreated)
+	}
+
+	logFactory.On("NewLogger", mock.Anything).Return(log)
+
+	sut := sf.NewMiddlewareWrapper(logFactory, &mockMetrics{}, &sf.CORSOptions{}, sf.ServiceGlobals{})
+	wrapped := sut.Wrap("my-sub", "my-name", sf.Idempotency(sf.IdempotencyOptions{}), handle, nil)
+
+	serve := func(remoteAddr string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "https://www.sf.com/payments", strings.NewReader("{}"))
+		r.RemoteAddr = remoteAddr
+		r.Header.Set(sf.IdempotencyKeyHeader, "key-1")
+		wrapped(sf.NewWrappedResponseWriter(w), r, sf.RouterParams{})
+		return w
+	}
+
+	// Act
+	serve("198.51.100.1:1234")
+	other := serve("198.51.100.2:1234")
+	replayed := serve("198.51.100.1:5678")
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	assert.Equal(t, "", other.Header().Get(sf.IdempotentReplayedHeader))
+	assert.Equal(t, "true", replayed.Header().Get(sf.IdempotentReplayedHeader))
+}
+
+func TestMiddlewareWrapperImpl_Wrap_IdempotencyInFlight(t *testing.T) {
+	logFactory := &mockLogFactory{}
+	log := &mockLogger{}
+	m := &mockMetrics{}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handle := func(w sf.WrappedResponseWriter, r *http.Request, _ sf.RouterParams) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	}
+
+	logFactory.On("NewLogger", mock.Anything).Return(log)
+
+	sut := sf.NewMiddlewareWrapper(logFactory, m, &sf.CORSOptions{}, sf.ServiceGlobals{})
+	wrapped := sut.Wrap("my-sub", "my-name", sf.Idempotency(sf.IdempotencyOptions{}), handle, nil)
+
+	serve := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "https://www.sf.com/payments", strings.NewReader("{}"))
+		r.Header.Set("Accept", sf.ContentTypeJSON)
+		r.Header.Set(sf.IdempotencyKeyHeader, "key-1")
+		wrapped(sf.NewWrappedResponseWriter(w), r, sf.RouterParams{})
+		return w
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- serve() }()
+	<-started
+
+	// Act
+	conflict := serve()
+	close(release)
+	first := <-done
+
+	assert.Equal(t, http.StatusConflict, conflict.Code)
+	assert.Equal(t, `{"Message":"Request with this idempotency key is in progress"}`+"\n", conflict.Body.String())
+	assert.Equal(t, http.StatusCreated, first.Code)
+}
+
+func TestMiddlewareWrapperImpl_Wrap_IdempotencyPanicReleasesKey(t *testing.T) {
+	logFactory := &mockLogFactory{}
+	log := &mockLogger{}
+	m := &mockMetrics{}
+	var calls int32
+	handle := func(w sf.WrappedResponseWriter, r *http.Request, _ sf.RouterParams) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			panic("boom")
+		}
+		w.WriteHeader(http.StatusCreated)
+	}
+
+	logFactory.On("NewLogger", mock.Anything).Return(log)
+
+	sut := sf.NewMiddlewareWrapper(logFactory, m, &sf.CORSOptions{}, sf.ServiceGlobals{})
+	wrapped := sut.Wrap("my-sub", "my-name", sf.Idempotency(sf.IdempotencyOptions{}), handle, nil)
+
+	serve := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "https://www.sf.com/payments", strings.NewReader("{}"))
+		r.Header.Set(sf.IdempotencyKeyHeader, "key-1")
+		wrapped(sf.NewWrappedResponseWriter(w), r, sf.RouterParams{})
+		return w
+	}
+
+	// Act
+	assert.Panics(t, func() { serve() })
+	w := serve()
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestMemoryIdempotencyStore_Expiry(t *testing.T) {
+	sut := sf.NewMemoryIdempotencyStore(0, 20*time.Millisecond)
+
+	// Act
+	_, first := sut.Begin("key", "fingerprint")
+	sut.Complete("key", sf.IdempotencyRecord{Fingerprint: "fingerprint", Status: http.StatusCreated})
+	record, second := sut.Begin("key", "fingerprint")
+	time.Sleep(30 * time.Millisecond)
+	_, third := sut.Begin("key", "fingerprint")
+
+	assert.True(t, first)
+	assert.False(t, second)
+	assert.Equal(t, http.StatusCreated, record.Status)
+	assert.False(t, record.InFlight)
+	assert.True(t, third)
+}
+
+func TestMemoryIdempotencyStore_MaxEntries(t *testing.T) {
+	sut := sf.NewMemoryIdempotencyStore(2, time.Hour)
+
+	// Act
+	sut.Begin("key-1", "fingerprint")
+	sut.Begin("key-2", "fingerprint")
+	sut.Begin("key-1", "fingerprint")
+	sut.Begin("key-3", "fingerprint")
+	_, kept := sut.Begin("key-1", "fingerprint")
+	_, evicted := sut.Begin("key-2", "fingerprint")
+
+	assert.True(t, evicted)
+	assert.False(t, kept)
+}
@@ -0,0 +1,129 @@
+package v8
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+type (
+	// IPListSource is a function signature for loading a list of IP addresses and CIDRs.
+	IPListSource func() ([]string, error)
+
+	// IPList is an interface for holding IP addresses and CIDRs that can be reloaded without a restart.
+	IPList interface {
+		// Contains returns whether the specified IP address is in the list.
+		Contains(ip string) bool
+		// Reload loads the list from its source. When loading or parsing fails, the current list is kept.
+		Reload() error
+	}
+
+	// IPFilterOptions contains properties used by the IPFilter middleware.
+	IPFilterOptions struct {
+		// Allow contains the clients that are permitted. When nil, all clients not in Deny are permitted.
+		Allow IPList
+		// Deny contains the clients that are blocked, also when they are in Allow.
+		Deny IPList
+	}
+
+	ipListImpl struct {
+		*reloadableSource
+	}
+)
+
+// IPFilter returns a Middleware enumeration that permits or blocks requests by the client IP address, as resolved by
+// the RealIP middleware. Blocked requests get http status-code 403. Use SubsystemMiddlewares to filter the requests of
+// a whole subsystem.
+func IPFilter(options IPFilterOptions) Middleware {
+	return registerMiddleware("", func(m *middlewareWrapperImpl, subsystem, name string, handler Handle,
+		_ MetaFunc) Handle {
+
+		return m.wrapWithIPFilter(subsystem, name, handler, options)
+	})
+}
+
+// NewIPList instantiates a new IPList implementation and loads the list. With a positive reload interval, the list is
+// reloaded when it is older than the interval; otherwise only Reload loads it again.
+func NewIPList(source IPListSource, reloadInterval time.Duration) (IPList, error) {
+	load := func() (interface{}, error) {
+		values, err := source()
+		if err != nil {
+			return nil, err
+		}
+		return ParseCIDRs(values)
+	}
+
+	reloadable, err := newReloadableSource(load, reloadInterval)
+	return &ipListImpl{reloadable}, err
+}
+
+// IPListFromValues returns an IPListSource with the specified IP addresses and CIDRs.
+func IPListFromValues(values ...string) IPListSource {
+	return func() ([]string, error) {
+		return values, nil
+	}
+}
+
+// IPListFromFile returns an IPListSource that reads an IP address or CIDR per line from the specified file. Empty
+// lines and lines starting with # are skipped.
+func IPListFromFile(path string) IPListSource {
+	return func() ([]string, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		var values []string
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			values = append(values, line)
+		}
+		return values, scanner.Err()
+	}
+}
+
+// IPListFromEnv returns an IPListSource that reads a comma-separated list of IP addresses and CIDRs from the specified
+// environment variable.
+func IPListFromEnv(name string) IPListSource {
+	return func() ([]string, error) {
+		return strings.Split(os.Getenv(name), ","), nil
+	}
+}
+
+/* IPList implementation */
+
+func (l *ipListImpl) Contains(ip string) bool {
+	nets, _ := l.get().([]*net.IPNet)
+	return containsIP(nets, ip)
+}
+
+func (m *middlewareWrapperImpl) wrapWithIPFilter(subsystem, name string, handler Handle,
+	options IPFilterOptions) Handle {
+
+	return func(w WrappedResponseWriter, r *http.Request, p RouterParams) {
+		ip := ClientIP(r)
+		blocked := options.Deny != nil && options.Deny.Contains(ip)
+		if !blocked && options.Allow != nil {
+			blocked = !options.Allow.Contains(ip)
+		}
+
+		if blocked {
+			writeErrorResponse(w, r, http.StatusForbidden, "Forbidden")
+
+			labels, values := m.getLabelsAndValues(subsystem, name, w, r)
+			m.metrics.CountLabels("", "http_requests_ip_blocked_total", "Total requests blocked by IP address.",
+				labels, values)
+			return
+		}
+
+		handler(w, r, p)
+	}
+}
@@ -0,0 +1,156 @@
+package v8_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sf "github.com/Travix-International/go-servicefoundation/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestIPList_Contains(t *testing.T) {
+	t.Setenv("SF_ALLOWED_IPS", "198.51.100.0/24, 2001:db8::1")
+	path := filepath.Join(t.TempDir(), "allowed")
+	os.WriteFile(path, []byte("# partners\n203.0.113.7\n\n10.0.0.0/8\n"), 0600)
+
+	fromEnv, envErr := sf.NewIPList(sf.IPListFromEnv("SF_ALLOWED_IPS"), 0)
+	fromFile, fileErr := sf.NewIPList(sf.IPListFromFile(path), 0)
+	_, invalidErr := sf.NewIPList(sf.IPListFromValues("10.0.0.0/99"), 0)
+
+	scenarios := []struct {
+		list     sf.IPList
+		ip       string
+		expected bool
+	}{
+		{fromEnv, "198.51.100.77", true},
+		{fromEnv, "2001:db8::1", true},
+		{fromEnv, "2001:db8::2", false},
+		{fromEnv, "203.0.113.7", false},
+		{fromFile, "203.0.113.7", true},
+		{fromFile, "10.1.2.3", true},
+		{fromFile, "198.51.100.77", false},
+		{fromFile, "not-an-ip", false},
+	}
+
+	for i, scenario := range scenarios {
+		// Act
+		actual := scenario.list.Contains(scenario.ip)
+
+		assert.Equal(t, scenario.expected, actual, "Scenario %d", i)
+	}
+	assert.Nil(t, envErr)
+	assert.Nil(t, fileErr)
+	assert.NotNil(t, invalidErr)
+}
+
+func TestIPList_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "allowed")
+	os.WriteFile(path, []byte("203.0.113.7\n"), 0600)
+	sut, _ := sf.NewIPList(sf.IPListFromFile(path), time.Hour)
+	os.WriteFile(path, []byte("203.0.113.8\n"), 0600)
+
+	// Act
+	before := sut.Contains("203.0.113.7")
+	err := sut.Reload()
+	after := sut.Contains("203.0.113.8")
+	os.WriteFile(path, []byte("invalid\n"), 0600)
+	failedErr := sut.Reload()
+
+	assert.True(t, before)
+	assert.Nil(t, err)
+	assert.True(t, after)
+	assert.NotNil(t, failedErr)
+	assert.True(t, sut.Contains("203.0.113.8"))
+	assert.False(t, sut.Contains("203.0.113.7"))
+}
+
+func TestIPList_ReloadsOnceForConcurrentRequests(t *testing.T) {
+	var loads int32
+	source := func() ([]string, error) {
+		if atomic.AddInt32(&loads, 1) > 1 {
+			time.Sleep(50 * time.Millisecond)
+		}
+		return []string{"203.0.113.7"}, nil
+	}
+	sut, _ := sf.NewIPList(source, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+
+	var wg sync.WaitGroup
+	results := make([]bool, 20)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = sut.Contains("203.0.113.7")
+		}(i)
+	}
+
+	// Act
+	wg.Wait()
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&loads))
+	for i, result := range results {
+		assert.True(t, result, "Request %d", i)
+	}
+}
+
+func TestMiddlewareWrapperImpl_Wrap_IPFilter(t *testing.T) {
+	allow, _ := sf.NewIPList(sf.IPListFromValues("198.51.100.0/24"), 0)
+	deny, _ := sf.NewIPList(sf.IPListFromValues("198.51.100.66", "203.0.113.0/24"), 0)
+
+	scenarios := []struct {
+		options        sf.IPFilterOptions
+		forwardedFor   string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{sf.IPFilterOptions{Allow: allow}, "198.51.100.1", http.StatusOK, "ok"},
+		{sf.IPFilterOptions{Allow: allow}, "192.0.2.1", http.StatusForbidden, `{"Message":"Forbidden"}` + "\n"},
+		{sf.IPFilterOptions{Allow: allow, Deny: deny}, "198.51.100.66", http.StatusForbidden,
+			`{"Message":"Forbidden"}` + "\n"},
+		{sf.IPFilterOptions{Deny: deny}, "203.0.113.5", http.StatusForbidden, `{"Message":"Forbidden"}` + "\n"},
+		{sf.IPFilterOptions{Deny: deny}, "192.0.2.1", http.StatusOK, "ok"},
+		{sf.IPFilterOptions{}, "192.0.2.1", http.StatusOK, "ok"},
+	}
+
+	for i, scenario := range scenarios {
+		logFactory := &mockLogFactory{}
+		log := &mockLogger{}
+		m := &mockMetrics{}
+		handle := func(w sf.WrappedResponseWriter, _ *http.Request, _ sf.RouterParams) {
+			w.Write([]byte("ok"))
+		}
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "https://www.sf.com/partners", nil)
+		r.Header.Set("Accept", sf.ContentTypeJSON)
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Set("X-Forwarded-For", scenario.forwardedFor)
+
+		logFactory.On("NewLogger", mock.Anything).Return(log)
+		m.On("CountLabels", "", "http_requests_ip_blocked_total", mock.Anything, mock.Anything, mock.Anything).
+			Return()
+
+		sut := sf.NewMiddlewareWrapper(logFactory, m, &sf.CORSOptions{}, sf.ServiceGlobals{})
+		filtered := sut.Wrap("my-sub", "my-name", sf.IPFilter(scenario.options), handle, nil)
+		realIP := sf.RealIP(sf.RealIPOptions{TrustedProxies: []string{"10.0.0.0/8"}})
+
+		// Act
+		sut.Wrap("my-sub", "my-name", realIP, filtered, nil)(sf.NewWrappedResponseWriter(w), r, sf.RouterParams{})
+
+		assert.Equal(t, scenario.expectedStatus, w.Code, "Scenario %d", i)
+		assert.Equal(t, scenario.expectedBody, w.Body.String(), "Scenario %d", i)
+		if scenario.expectedStatus == http.StatusForbidden {
+			m.AssertNumberOfCalls(t, "CountLabels", 1)
+		} else {
+			m.AssertNotCalled(t, "CountLabels", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+				mock.Anything)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package v8
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultJWKSRefreshInterval = time.Hour
+	// jwksMinRefetchInterval limits refetching a JWKS URL for unknown key IDs and after failures.
+	jwksMinRefetchInterval = 10 * time.Second
+	jwksFetchTimeout       = 10 * time.Second
+)
+
+type (
+	// JWTKeySet is an interface for providing the keys used to verify JWT signatures. Keys are *rsa.PublicKey,
+	// *ecdsa.PublicKey or []byte values for RS256, ES256 and HS256 respectively.
+	JWTKeySet interface {
+		// Keys returns the keys with the specified key ID, or all keys when the key ID is empty.
+		Keys(kid string) ([]interface{}, error)
+	}
+
+	staticKeySet struct {
+		keys map[string][]interface{}
+		all  []interface{}
+	}
+
+	remoteKeySet struct {
+		mutex           sync.Mutex
+		url             string
+		client          *http.Client
+		refreshInterval time.Duration
+		keySet          *staticKeySet
+		fetched         time.Time
+		attempted       time.Time
+		err             error
+		// fetching is closed when the running fetch finishes, and nil when no fetch is running.
+		fetching chan struct{}
+	}
+
+	jsonWebKeySet struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+
+	jsonWebKey struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+		K   string `json:"k"`
+	}
+)
+
+// ParseJWKS creates a JWTKeySet from a JSON Web Key Set document. RSA, EC (P-256) and oct keys are supported; keys
+// of other types or for purposes other than signing are skipped.
+func ParseJWKS(data []byte) (JWTKeySet, error) {
+	return parseJWKS(data)
+}
+
+// NewJWKSFile creates a JWTKeySet from the JSON Web Key Set file with the specified path.
+func NewJWKSFile(path string) (JWTKeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseJWKS(data)
+}
+
+// NewJWKSURL creates a JWTKeySet that fetches the JSON Web Key Set from the specified URL. The keys are cached for
+// the refresh interval, with a default of one hour, and refetched early when a token uses an unknown key ID. When
+// refetching fails, the cached keys are used and refetching is retried after 10 seconds. Fetching does not block
+// requests that can be verified with the cached keys.
+func NewJWKSURL(url string, refreshInterval time.Duration) JWTKeySet {
+	if refreshInterval <= 0 {
+		refreshInterval = defaultJWKSRefreshInterval
+	}
+
+	return &remoteKeySet{
+		url:             url,
+		client:          &http.Client{Timeout: jwksFetchTimeout},
+		refreshInterval: refreshInterval,
+	}
+}
+
+/* JWTKeySet implementation */
+
+func (s *staticKeySet) Keys(kid string) ([]interface{}, error) {
+	if kid == "" {
+		return s.all, nil
+	}
+	return s.keys[kid], nil
+}
+
+func (s *remoteKeySet) Keys(kid string) ([]interface{}, error) {
+	s.mutex.Lock()
+
+	var keys []interface{}
+	if s.keySet != nil {
+		keys, _ = s.keySet.Keys(kid)
+	}
+
+	now := time.Now()
+	stale := len(keys) == 0 || now.Sub(s.fetched) >= s.refreshInterval
+	if stale && s.fetching == nil && now.Sub(s.attempted) >= jwksMinRefetchInterval {
+		s.attempted = now
+		s.fetching = make(chan struct{})
+		go s.refresh(s.fetching)
+	}
+
+	keySet, err, fetching := s.keySet, s.err, s.fetching
+	s.mutex.Unlock()
+
+	if len(keys) > 0 || fetching == nil {
+		// Known keys are served from the cache while a refresh runs in the background.
+		if keySet == nil {
+			return nil, err
+		}
+		return keys, nil
+	}
+
+	// Only requests that need the fetched keys wait for it, without holding the lock.
+	<-fetching
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.keySet == nil {
+		return nil, s.err
+	}
+	return s.keySet.Keys(kid)
+}
+
+// refresh fetches the keys and closes the done channel when finished. The cached keys are kept when fetching fails.
+func (s *remoteKeySet) refresh(done chan struct{}) {
+	keySet, err := s.fetch()
+
+	s.mutex.Lock()
+	if err == nil {
+		s.keySet = keySet
+		s.fetched = time.Now()
+	}
+	s.err = err
+	s.fetching = nil
+	s.mutex.Unlock()
+
+	close(done)
+}
+
+func (s *remoteKeySet) fetch() (*staticKeySet, error) {
+	resp, err := s.client.Get(s.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching JWKS from %s returned status %d", s.url, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return parseJWKS(data)
+}
+
+func parseJWKS(data []byte) (*staticKeySet, error) {
+	var set jsonWebKeySet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keySet := &staticKeySet{keys: make(map[string][]interface{})}
+
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := jwk.key()
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWK '%s': %w", jwk.Kid, err)
+		}
+		if key == nil {
+			continue
+		}
+
+		keySet.keys[jwk.Kid] = append(keySet.keys[jwk.Kid], key)
+		keySet.all = append(keySet.all, key)
+	}
+	return keySet, nil
+}
+
+// key returns the public or secret key of the JWK, or nil for unsupported key types.
+func (jwk jsonWebKey) key() (interface{}, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("RSA exponent is too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if jwk.Crv != "P-256" {
+			return nil, nil
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("invalid P-256 coordinates")
+		}
+		if _, err = ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "oct":
+		return base64.RawURLEncoding.DecodeString(jwk.K)
+	default:
+		return nil, nil
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,65 @@
+package v8_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sf "github.com/Travix-International/go-servicefoundation/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewJWKSURL(t *testing.T) {
+	keys := newJWTTestKeys(t)
+	var fetches int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fetches++
+		fmt.Fprint(w, string(keys.jwksDoc))
+	}))
+	defer server.Close()
+
+	sut := sf.NewJWKSURL(server.URL, time.Hour)
+
+	// Act
+	rsaKeys, rsaErr := sut.Keys("rsa")
+	unknownKeys, unknownErr := sut.Keys("unknown")
+
+	assert.Nil(t, rsaErr)
+	assert.Len(t, rsaKeys, 1)
+	assert.Nil(t, unknownErr)
+	assert.Len(t, unknownKeys, 0)
+	assert.Equal(t, 1, fetches)
+}
+
+func TestNewJWKSURL_FetchesOnceForConcurrentRequests(t *testing.T) {
+	keys := newJWTTestKeys(t)
+	var fetches int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		fmt.Fprint(w, string(keys.jwksDoc))
+	}))
+	defer server.Close()
+
+	sut := sf.NewJWKSURL(server.URL, time.Hour)
+	results := make(chan int, 5)
+
+	// Act
+	for i := 0; i < cap(results); i++ {
+		go func() {
+			rsaKeys, _ := sut.Keys("rsa")
+			results <- len(rsaKeys)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	for i := 0; i < cap(results); i++ {
+		assert.Equal(t, 1, <-results, "Request %d", i)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+}
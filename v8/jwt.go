@@ -0,0 +1,289 @@
+package v8
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const bearerPrefix = "bearer "
+
+type (
+	// JWTOptions contains properties used by the Auth middleware.
+	JWTOptions struct {
+		// KeySet provides the keys to verify token signatures with, see NewJWKSFile and NewJWKSURL.
+		KeySet JWTKeySet
+		// Issuer is the required value of the iss claim. When empty, the issuer is not checked.
+		Issuer string
+		// Audience is the value required in the aud claim. When empty, the audience is not checked.
+		Audience string
+		// Leeway is the allowed clock skew when checking the exp and nbf claims.
+		Leeway time.Duration
+		// Scopes contains the scopes the token needs to have, taken from the scope or scp claim.
+		Scopes []string
+		// Roles contains the roles the token needs to have, taken from the roles claim.
+		Roles []string
+		// LogClaims contains the claims that are added to the log messages of the request as "entry.auth.<claim>".
+		// Default value is ["sub"].
+		LogClaims []string
+	}
+
+	// Claims contains the claims of a validated JWT.
+	Claims map[string]interface{}
+
+	jwtHeader struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+
+	claimsKey struct{}
+)
+
+var (
+	errMissingToken      = errors.New("missing bearer token")
+	errMalformedToken    = errors.New("malformed token")
+	errUnsupportedAlg    = errors.New("unsupported signing algorithm")
+	errInvalidSignature  = errors.New("invalid signature")
+	errKeysUnavailable   = errors.New("signing keys unavailable")
+	errTokenExpired      = errors.New("token is expired")
+	errTokenNotValidYet  = errors.New("token is not valid yet")
+	errInvalidIssuer     = errors.New("invalid issuer")
+	errInvalidAudience   = errors.New("invalid audience")
+	errInsufficientScope = errors.New("insufficient scope")
+)
+
+// Auth returns a Middleware enumeration that validates the JWT bearer token of a request. Invalid tokens get http
+// status-code 401, tokens without the required scopes or roles get http status-code 403. The claims are available
+// through ClaimsFromContext. Routes using the returned Middleware share the key set of the options.
+func Auth(options JWTOptions) Middleware {
+	if options.LogClaims == nil {
+		options.LogClaims = []string{"sub"}
+	}
+
+	return registerRequestMetaMiddleware(func(m *middlewareWrapperImpl, subsystem, name string, handler Handle,
+		_ MetaFunc) Handle {
+
+		return m.wrapWithAuth(handler, options)
+	})
+}
+
+// ClaimsFromContext returns the claims stored by the Auth middleware.
+func ClaimsFromContext(ctx context.Context) (Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(Claims)
+	return claims, ok
+}
+
+// String returns the value of the specified claim when it is a string.
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Subject returns the sub claim.
+func (c Claims) Subject() string {
+	return c.String("sub")
+}
+
+// Strings returns the value of the specified claim as a list, supporting both a space-separated string and an array
+// of strings.
+func (c Claims) Strings(name string) []string {
+	switch value := c[name].(type) {
+	case string:
+		return strings.Fields(value)
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// Scopes returns the scopes of the scope or scp claim.
+func (c Claims) Scopes() []string {
+	if _, ok := c["scope"]; ok {
+		return c.Strings("scope")
+	}
+	return c.Strings("scp")
+}
+
+// Roles returns the roles of the roles claim.
+func (c Claims) Roles() []string {
+	return c.Strings("roles")
+}
+
+// ValidateJWT verifies the signature and claims of the specified token and returns its claims. Scope and role
+// requirements are not checked.
+func ValidateJWT(token string, options JWTOptions) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errMalformedToken
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, errMalformedToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errMalformedToken
+	}
+
+	if err = verifySignature(options.KeySet, header, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	claims := Claims{}
+	if err = decodeSegment(parts[1], &claims); err != nil {
+		return nil, errMalformedToken
+	}
+
+	if err = validateClaims(claims, options); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (m *middlewareWrapperImpl) wrapWithAuth(handler Handle, options JWTOptions) Handle {
+	return func(w WrappedResponseWriter, r *http.Request, p RouterParams) {
+		token := r.Header.Get("Authorization")
+		if len(token) < len(bearerPrefix) || !strings.EqualFold(token[:len(bearerPrefix)], bearerPrefix) {
+			writeAuthError(w, r, http.StatusUnauthorized, "", errMissingToken)
+			return
+		}
+
+		claims, err := ValidateJWT(strings.TrimSpace(token[len(bearerPrefix):]), options)
+		if err != nil {
+			writeAuthError(w, r, http.StatusUnauthorized, "invalid_token", err)
+			return
+		}
+
+		for _, claim := range options.LogClaims {
+			if value := claims.String(claim); value != "" {
+				AddRequestMeta(r, "entry.auth."+claim, value)
+			}
+		}
+
+		if !containsAll(claims.Scopes(), options.Scopes) || !containsAll(claims.Roles(), options.Roles) {
+			writeAuthError(w, r, http.StatusForbidden, "insufficient_scope", errInsufficientScope)
+			return
+		}
+
+		handler(w, r.WithContext(context.WithValue(r.Context(), claimsKey{}, claims)), p)
+	}
+}
+
+func writeAuthError(w WrappedResponseWriter, r *http.Request, statusCode int, code string, err error) {
+	challenge := "Bearer"
+	if code != "" {
+		challenge = fmt.Sprintf(`Bearer error="%s"`, code)
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	writeErrorResponse(w, r, statusCode, err.Error())
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// verifySignature verifies the signature against the keys with the key ID of the token. The key type has to match
+// the algorithm, so a public key can not be abused as HMAC secret.
+func verifySignature(keySet JWTKeySet, header jwtHeader, signed string, signature []byte) error {
+	if keySet == nil {
+		return errInvalidSignature
+	}
+
+	switch header.Alg {
+	case "RS256", "ES256", "HS256":
+	default:
+		return errUnsupportedAlg
+	}
+
+	keys, err := keySet.Keys(header.Kid)
+	if err != nil {
+		return errKeysUnavailable
+	}
+
+	hash := sha256.Sum256([]byte(signed))
+
+	for _, key := range keys {
+		switch header.Alg {
+		case "RS256":
+			if k, ok := key.(*rsa.PublicKey); ok && rsa.VerifyPKCS1v15(k, crypto.SHA256, hash[:], signature) == nil {
+				return nil
+			}
+		case "ES256":
+			if k, ok := key.(*ecdsa.PublicKey); ok && len(signature) == 64 {
+				r := new(big.Int).SetBytes(signature[:32])
+				s := new(big.Int).SetBytes(signature[32:])
+				if ecdsa.Verify(k, hash[:], r, s) {
+					return nil
+				}
+			}
+		case "HS256":
+			if k, ok := key.([]byte); ok {
+				mac := hmac.New(sha256.New, k)
+				mac.Write([]byte(signed))
+				if hmac.Equal(mac.Sum(nil), signature) {
+					return nil
+				}
+			}
+		}
+	}
+	return errInvalidSignature
+}
+
+func validateClaims(claims Claims, options JWTOptions) error {
+	now := time.Now()
+
+	if exp, ok := claims["exp"].(float64); ok && now.After(time.Unix(int64(exp), 0).Add(options.Leeway)) {
+		return errTokenExpired
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Before(time.Unix(int64(nbf), 0).Add(-options.Leeway)) {
+		return errTokenNotValidYet
+	}
+	if options.Issuer != "" && claims.String("iss") != options.Issuer {
+		return errInvalidIssuer
+	}
+	if options.Audience != "" && !containsAll(claims.Strings("aud"), []string{options.Audience}) {
+		return errInvalidAudience
+	}
+	return nil
+}
+
+// containsAll returns whether all required values are present in the specified values.
+func containsAll(values, required []string) bool {
+	for _, r := range required {
+		found := false
+		for _, v := range values {
+			if v == r {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
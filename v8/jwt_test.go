@@ -0,0 +1,160 @@
+package v8_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sf "github.com/Travix-International/go-servicefoundation/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type jwtTestKeys struct {
+	rsaKey  *rsa.PrivateKey
+	ecKey   *ecdsa.PrivateKey
+	secret  []byte
+	keySet  sf.JWTKeySet
+	jwksDoc []byte
+}
+
+func newJWTTestKeys(t *testing.T) *jwtTestKeys {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	secret := []byte("my-secret")
+	enc := base64.RawURLEncoding.EncodeToString
+
+	doc, _ := json.Marshal(map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "n": enc(rsaKey.N.Bytes()),
+			"e": enc(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": enc(ecKey.X.FillBytes(make([]byte, 32))),
+			"y": enc(ecKey.Y.FillBytes(make([]byte, 32)))},
+		{"kty": "oct", "kid": "hmac", "k": enc(secret)},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": "", "e": ""},
+	}})
+
+	keySet, err := sf.ParseJWKS(doc)
+	assert.Nil(t, err)
+	return &jwtTestKeys{rsaKey: rsaKey, ecKey: ecKey, secret: secret, keySet: keySet, jwksDoc: doc}
+}
+
+func (k *jwtTestKeys) sign(alg, kid string, claims map[string]interface{}) string {
+	enc := base64.RawURLEncoding.EncodeToString
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := enc(header) + "." + enc(payload)
+	hash := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch alg {
+	case "RS256":
+		signature, _ = rsa.SignPKCS1v15(rand.Reader, k.rsaKey, crypto.SHA256, hash[:])
+	case "ES256":
+		r, s, _ := ecdsa.Sign(rand.Reader, k.ecKey, hash[:])
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case "HS256":
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	}
+	return signed + "." + enc(signature)
+}
+
+func TestValidateJWT(t *testing.T) {
+	keys := newJWTTestKeys(t)
+	now := time.Now().Unix()
+	valid := map[string]interface{}{"sub": "user-1", "iss": "my-issuer", "aud": []string{"my-api"}, "exp": now + 60}
+	options := sf.JWTOptions{KeySet: keys.keySet, Issuer: "my-issuer", Audience: "my-api"}
+
+	scenarios := []struct {
+		token       string
+		expectedErr string
+	}{
+		{keys.sign("RS256", "rsa", valid), ""},
+		{keys.sign("ES256", "ec", valid), ""},
+		{keys.sign("HS256", "hmac", valid), ""},
+		{keys.sign("HS256", "", valid), ""},
+		{keys.sign("RS256", "ec", valid), "invalid signature"},
+		{keys.sign("none", "", valid), "unsupported signing algorithm"},
+		{keys.sign("RS256", "rsa", valid) + "x", "invalid signature"},
+		{"abc.def", "malformed token"},
+		{keys.sign("RS256", "rsa", map[string]interface{}{"exp": now - 60}), "token is expired"},
+		{keys.sign("RS256", "rsa", map[string]interface{}{"nbf": now + 60}), "token is not valid yet"},
+		{keys.sign("RS256", "rsa", map[string]interface{}{"iss": "other"}), "invalid issuer"},
+		{keys.sign("RS256", "rsa", map[string]interface{}{"iss": "my-issuer", "aud": "other"}), "invalid audience"},
+	}
+
+	for i, scenario := range scenarios {
+		// Act
+		claims, err := sf.ValidateJWT(scenario.token, options)
+
+		if scenario.expectedErr == "" {
+			assert.Nil(t, err, "Scenario %d", i)
+			assert.Equal(t, "user-1", claims.Subject(), "Scenario %d", i)
+		} else {
+			assert.EqualError(t, err, scenario.expectedErr, "Scenario %d", i)
+		}
+	}
+}
+
+func TestMiddlewareWrapperImpl_Wrap_Auth(t *testing.T) {
+	keys := newJWTTestKeys(t)
+	claims := map[string]interface{}{"sub": "user-1", "scope": "read write", "roles": []string{"admin"}}
+
+	scenarios := []struct {
+		authorization  string
+		scopes         []string
+		roles          []string
+		expectedStatus int
+		expectedHeader string
+	}{
+		{"Bearer " + keys.sign("RS256", "rsa", claims), []string{"read"}, []string{"admin"}, http.StatusOK, ""},
+		{"", nil, nil, http.StatusUnauthorized, "Bearer"},
+		{"Basic dXNlcjpwYXNz", nil, nil, http.StatusUnauthorized, "Bearer"},
+		{"Bearer abc", nil, nil, http.StatusUnauthorized, `Bearer error="invalid_token"`},
+		{"Bearer " + keys.sign("RS256", "rsa", claims), []string{"delete"}, nil, http.StatusForbidden,
+			`Bearer error="insufficient_scope"`},
+		{"Bearer " + keys.sign("RS256", "rsa", claims), nil, []string{"owner"}, http.StatusForbidden,
+			`Bearer error="insufficient_scope"`},
+	}
+
+	for i, scenario := range scenarios {
+		logFactory := &mockLogFactory{}
+		log := &mockLogger{}
+		m := &mockMetrics{}
+		var actual sf.Claims
+		handle := func(w sf.WrappedResponseWriter, r *http.Request, _ sf.RouterParams) {
+			actual, _ = sf.ClaimsFromContext(r.Context())
+			w.WriteHeader(http.StatusOK)
+		}
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "https://www.sf.com/some/url", nil)
+		r.Header.Set("Authorization", scenario.authorization)
+
+		logFactory.On("NewLogger", mock.Anything).Return(log)
+
+		sut := sf.NewMiddlewareWrapper(logFactory, m, &sf.CORSOptions{}, sf.ServiceGlobals{})
+		middleware := sf.Auth(sf.JWTOptions{KeySet: keys.keySet, Scopes: scenario.scopes, Roles: scenario.roles})
+
+		// Act
+		sut.Wrap("my-sub", "my-name", middleware, handle, nil)(sf.NewWrappedResponseWriter(w), r, sf.RouterParams{})
+
+		assert.Equal(t, scenario.expectedStatus, w.Code, "Scenario %d", i)
+		assert.Equal(t, scenario.expectedHeader, w.Header().Get("WWW-Authenticate"), "Scenario %d", i)
+		if scenario.expectedStatus == http.StatusOK {
+			assert.Equal(t, "user-1", actual.Subject(), "Scenario %d", i)
+			assert.Equal(t, []string{"read", "write"}, actual.Scopes(), "Scenario %d", i)
+		}
+	}
+}
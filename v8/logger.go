@@ -130,6 +130,7 @@ func (l *loggerImpl) Info(event, formatOrMsg string, a ...interface{}) {
 
 	if len(a) == 0 {
 		l.logger.Info(event).Log(formatOrMsg)
+		return
 	}
 	l.logger.Info(event).Logf(formatOrMsg, a...)
 }
@@ -141,6 +142,7 @@ func (l *loggerImpl) Warn(event, formatOrMsg string, a ...interface{}) {
 
 	if len(a) == 0 {
 		l.logger.Warn(event).Log(formatOrMsg)
+		return
 	}
 	l.logger.Warn(event).Logf(formatOrMsg, a...)
 }
@@ -148,6 +150,7 @@ func (l *loggerImpl) Warn(event, formatOrMsg string, a ...interface{}) {
 func (l *loggerImpl) Error(event, formatOrMsg string, a ...interface{}) {
 	if len(a) == 0 {
 		l.logger.Error(event).Log(formatOrMsg)
+		return
 	}
 	l.logger.Error(event).Logf(formatOrMsg, a...)
 }
@@ -1,6 +1,9 @@
 package v8
 
 import (
+	"io"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -81,3 +84,22 @@ func TestLoggerImpl_GetLogger_CombineMetas(t *testing.T) {
 
 	assert.NotNil(t, logger)
 }
+
+func TestLoggerImpl_StaticMsg_LoggedOnce(t *testing.T) {
+	reader, writer, err := os.Pipe()
+	assert.NoError(t, err)
+	stderr := os.Stderr
+	os.Stderr = writer
+	factory := NewLogFactory("Debug", make(map[string]string))
+	sut := factory.NewLogger(make(map[string]string))
+	os.Stderr = stderr
+
+	// Act
+	sut.Info("event", "msg")
+	sut.Warn("event", "msg")
+	sut.Error("event", "msg")
+	writer.Close()
+
+	output, _ := io.ReadAll(reader)
+	assert.Equal(t, 3, strings.Count(string(output), "\n"))
+}
@@ -6,6 +6,7 @@ import (
 	"runtime/debug"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/rs/cors"
@@ -26,16 +27,48 @@ const (
 	RequestLogging Middleware = 6
 	// RequestMetrics is a middleware enumeration to measure the incoming request and response times.
 	RequestMetrics Middleware = 7
+	// RequestID is a middleware enumeration to identify the request with the incoming X-Request-ID or
+	// X-Correlation-ID header, or a new UUID. The ID is echoed in the response and added to the log messages.
+	RequestID Middleware = 8
+	// Compression is a middleware enumeration to compress responses with gzip or brotli, depending on the
+	// Accept-Encoding header. Use CompressionWith for other than the default options.
+	Compression Middleware = 9
+	// ETag is a middleware enumeration to add a strong ETag to GET and HEAD responses and answer conditional requests
+	// with http status-code 304. Use ETagWith for weak ETags.
+	ETag Middleware = 10
+
+	// firstRegisteredMiddleware is the first enumeration value handed out by RegisterMiddleware, leaving room for
+	// predefined middleware.
+	firstRegisteredMiddleware Middleware = 1000
 )
 
 type (
-	// Middleware is an enumeration to indicate the available middleware wrappers.
+	// Middleware is an enumeration to indicate the available middleware wrappers. Constructors of configurable
+	// middleware, like RateLimit or Timeout, register a new enumeration on every call, so call them once per
+	// configuration when adding routes.
 	Middleware int
 
 	// MiddlewareWrapper is an interface to wrap an existing handler with the specified middleware.
 	MiddlewareWrapper interface {
 		Wrap(subsystem, name string, middleware Middleware, handler Handle, metaFunc MetaFunc) Handle
 	}
+
+	// MiddlewareFunc is a function signature for user-defined middleware. It receives the subsystem and name of the
+	// route, the handler to wrap and the MetaFunc of the route.
+	MiddlewareFunc func(subsystem, name string, handler Handle, metaFunc MetaFunc) Handle
+
+	middlewareRegistration struct {
+		name        string
+		wrap        func(m *middlewareWrapperImpl, subsystem, name string, handler Handle, metaFunc MetaFunc) Handle
+		requestMeta bool
+	}
+)
+
+var (
+	registeredMiddlewares     = make(map[Middleware]*middlewareRegistration)
+	registeredMiddlewareNames = make(map[string]Middleware)
+	nextRegisteredMiddleware  = firstRegisteredMiddleware
+	registeredMiddlewareMutex = &sync.RWMutex{}
 )
 
 type middlewareWrapperImpl struct {
@@ -58,6 +91,73 @@ func NewMiddlewareWrapper(logFactory LogFactory, metrics Metrics, corsOptions *C
 	return m
 }
 
+// RegisterMiddleware registers user-defined middleware under the specified name and returns the Middleware
+// enumeration to use alongside the predefined middleware when adding routes. It panics when the name is already
+// registered.
+func RegisterMiddleware(name string, middleware MiddlewareFunc) Middleware {
+	return registerMiddleware(name, func(_ *middlewareWrapperImpl, subsystem, name string, handler Handle,
+		metaFunc MetaFunc) Handle {
+
+		return middleware(subsystem, name, handler, metaFunc)
+	})
+}
+
+// RegisterHTTPMiddleware registers standard net/http middleware under the specified name and returns the Middleware
+// enumeration to use alongside the predefined middleware when adding routes. It panics when the name is already
+// registered.
+func RegisterHTTPMiddleware(name string, middleware HTTPMiddleware) Middleware {
+	wrap := FromHTTPMiddleware(middleware)
+
+	return RegisterMiddleware(name, func(_, _ string, handler Handle, _ MetaFunc) Handle {
+		return wrap(handler)
+	})
+}
+
+// LookupMiddleware returns the Middleware enumeration registered under the specified name.
+func LookupMiddleware(name string) (Middleware, bool) {
+	registeredMiddlewareMutex.RLock()
+	defer registeredMiddlewareMutex.RUnlock()
+
+	middleware, exists := registeredMiddlewareNames[name]
+	return middleware, exists
+}
+
+// registerMiddleware registers the specified wrap function and returns its Middleware enumeration. Anonymous
+// registrations, which have an empty name, cannot be looked up.
+func registerMiddleware(name string, wrap func(m *middlewareWrapperImpl, subsystem, name string, handler Handle,
+	metaFunc MetaFunc) Handle) Middleware {
+
+	return addRegistration(&middlewareRegistration{name: name, wrap: wrap})
+}
+
+// addRegistration adds the complete registration and returns its Middleware enumeration.
+func addRegistration(registration *middlewareRegistration) Middleware {
+	registeredMiddlewareMutex.Lock()
+	defer registeredMiddlewareMutex.Unlock()
+
+	name := registration.name
+	if _, exists := registeredMiddlewareNames[name]; exists {
+		panic(fmt.Errorf("Middleware already registered: %s", name))
+	}
+
+	middleware := nextRegisteredMiddleware
+	nextRegisteredMiddleware++
+
+	registeredMiddlewares[middleware] = registration
+	if name != "" {
+		registeredMiddlewareNames[name] = middleware
+	}
+	return middleware
+}
+
+func getRegisteredMiddleware(middleware Middleware) (*middlewareRegistration, bool) {
+	registeredMiddlewareMutex.RLock()
+	defer registeredMiddlewareMutex.RUnlock()
+
+	registration, exists := registeredMiddlewares[middleware]
+	return registration, exists
+}
+
 /* MiddlewareWrapper implementation */
 
 func (m *middlewareWrapperImpl) Wrap(subsystem, name string, middleware Middleware, handler Handle, metaFunc MetaFunc) Handle {
@@ -73,19 +173,29 @@ func (m *middlewareWrapperImpl) Wrap(subsystem, name string, middleware Middlewa
 	case PanicTo500:
 		return m.wrapWithPanicHandler(subsystem, name, handler, metaFunc)
 	case RequestLogging:
-		return m.wrapWithRequestLogging(subsystem, name, handler, metaFunc)
+		return m.wrapWithRequestLogging(subsystem, name, handler, metaFunc, RequestLoggingOptions{})
 	case RequestMetrics:
 		return m.wrapWithRequestMetrics(subsystem, name, handler)
+	case RequestID:
+		return m.wrapWithRequestID(subsystem, name, handler)
+	case Compression:
+		return m.wrapWithCompression(handler)
+	case ETag:
+		return m.wrapWithETag(handler, ETagOptions{})
 	default:
+		if registration, exists := getRegisteredMiddleware(middleware); exists {
+			return registration.wrap(m, subsystem, name, handler, metaFunc)
+		}
 		m.log.Warn("UnhandledMiddleware", "Unhandled middleware: %v", middleware)
 	}
 	return handler
 }
 
 func (m *middlewareWrapperImpl) wrapWithCounter(subsystem, name string, handler Handle) Handle {
+	counterName := fmt.Sprintf("%v_total", strings.ToLower(name))
+	counterHelp := fmt.Sprintf("Totals for %v.", name)
+
 	return func(w WrappedResponseWriter, r *http.Request, p RouterParams) {
-		counterName := fmt.Sprintf("%v_total", strings.ToLower(name))
-		counterHelp := fmt.Sprintf("Totals for %v.", name)
 		labels, values := m.getLabelsAndValues(subsystem, name, w, r)
 
 		m.metrics.CountLabels("", counterName, counterHelp, labels, values)
@@ -95,10 +205,10 @@ func (m *middlewareWrapperImpl) wrapWithCounter(subsystem, name string, handler
 }
 
 func (m *middlewareWrapperImpl) wrapWithHistogram(subsystem, name string, handler Handle) Handle {
-	return func(w WrappedResponseWriter, r *http.Request, p RouterParams) {
-		histogramName := fmt.Sprintf("%v_duration_milliseconds", strings.ToLower(name))
-		histogramHelp := fmt.Sprintf("Response times for %v in milliseconds.", name)
+	histogramName := fmt.Sprintf("%v_duration_milliseconds", strings.ToLower(name))
+	histogramHelp := fmt.Sprintf("Response times for %v in milliseconds.", name)
 
+	return func(w WrappedResponseWriter, r *http.Request, p RouterParams) {
 		labels, values := m.getLabelsAndValues(subsystem, name, w, r)
 		hist := m.metrics.AddHistogramVec(subsystem, histogramName, histogramHelp, labels, values)
 		start := time.Now()
@@ -109,55 +219,20 @@ func (m *middlewareWrapperImpl) wrapWithHistogram(subsystem, name string, handle
 	}
 }
 
-func (m *middlewareWrapperImpl) wrapWithRequestLogging(subsystem, name string, handler Handle, metaFunc MetaFunc) Handle {
-	return func(w WrappedResponseWriter, r *http.Request, p RouterParams) {
-		meta := metaFunc(r, p)
-		log := m.getMetaLog(subsystem, name, nil, r, p, meta)
-		log.Info("ApiRequest", m.getRequestStartMessage(r, p, meta))
-
-		start := time.Now()
-
-		handler(w, r, p)
-
-		elapsedMs := float64(time.Since(start).Nanoseconds()/int64(time.Microsecond)) / 1000.0
-		durationMs := strconv.FormatFloat(elapsedMs, 'f', 3, 64)
-
-		meta["entry.duration"] = durationMs
-		log = m.getMetaLog(subsystem, name, w, r, p, meta)
-		log.Info("ApiResponse", m.getRequestEndMessage(w, r, p, meta, durationMs))
-	}
-}
-
-func (m *middlewareWrapperImpl) getRequestStartMessage(r *http.Request, p RouterParams, meta map[string]string) string {
-	return fmt.Sprintf("%s %s", r.Method, meta["entry.http.url"])
-}
-
-func (m *middlewareWrapperImpl) getRequestEndMessage(w WrappedResponseWriter, r *http.Request, p RouterParams, meta map[string]string, durationMs string) string {
-	status := strconv.Itoa(w.Status())
-	contentType := w.Header().Get("content-type")
-
-	return fmt.Sprintf("%s %s finished. Duration: %sms. Status: %s, ContentType: %s",
-		r.Method,
-		meta["entry.http.url"],
-		durationMs,
-		status,
-		contentType,
-	)
+func (m *middlewareWrapperImpl) getMetaLog(subsystem, name string, w WrappedResponseWriter, r *http.Request, p RouterParams, meta map[string]string) Logger {
+	return m.logFactory.NewLogger(m.getMeta(subsystem, name, w, r, p, meta))
 }
 
-func (m *middlewareWrapperImpl) getMetaLog(subsystem, name string, w WrappedResponseWriter, r *http.Request, p RouterParams, meta map[string]string) Logger {
+// getMeta adds the request, and the response when w is not nil, to the log meta.
+func (m *middlewareWrapperImpl) getMeta(subsystem, name string, w WrappedResponseWriter, r *http.Request, p RouterParams, meta map[string]string) map[string]string {
 	m.addMetaEntry(meta, "http.method", r.Method)
 	m.addMetaEntry(meta, "http.host", r.Host)
+	m.addMetaEntry(meta, "client.ip", ClientIP(r))
 
 	url := r.RequestURI
 
 	if r.URL != nil {
-		scheme := "http"
-		if r.URL.Scheme != "" {
-			scheme = r.URL.Scheme
-		} else if r.TLS != nil {
-			scheme = "https"
-		}
+		scheme := ClientScheme(r)
 		url = fmt.Sprintf("%s://%s%s", scheme, r.Host, r.RequestURI)
 		m.addMetaEntry(meta, "http.url", url)
 		m.addMetaEntry(meta, "http.query", r.URL.RawQuery)
@@ -166,6 +241,7 @@ func (m *middlewareWrapperImpl) getMetaLog(subsystem, name string, w WrappedResp
 	}
 
 	m.addMetaEntry(meta, "request", fmt.Sprintf("%s %s", r.Method, url))
+	copyRequestMeta(r, meta)
 
 	if w != nil {
 		m.addMetaEntry(meta, "statuscode", strconv.Itoa(w.Status()))
@@ -175,7 +251,7 @@ func (m *middlewareWrapperImpl) getMetaLog(subsystem, name string, w WrappedResp
 		}
 	}
 
-	return m.logFactory.NewLogger(meta)
+	return meta
 }
 
 func (m *middlewareWrapperImpl) addMetaEntry(meta map[string]string, key, value string) {
@@ -233,14 +309,17 @@ func (m *middlewareWrapperImpl) wrapWithNoCache(subsystem, name string, handler
 }
 
 func (m *middlewareWrapperImpl) wrapWithCORS(subsystem, name string, handler Handle) Handle {
+	c := cors.New(*m.corsOptions)
+
 	return func(w WrappedResponseWriter, r *http.Request, p RouterParams) {
-		c := cors.New(*m.corsOptions)
+		c.HandlerFunc(w, r)
 
-		h := func(ww http.ResponseWriter, r *http.Request) {
-			w := NewWrappedResponseWriter(ww)
-			handler(w, r, p)
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			// Pre-flight requests are standalone and should stop the chain.
+			w.WriteHeader(http.StatusOK)
+			return
 		}
-		c.ServeHTTP(w, r, h)
+		handler(w, r, p)
 	}
 }
 
@@ -3,6 +3,7 @@ package v8_test
 import (
 	"crypto/tls"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	sf "github.com/Travix-International/go-servicefoundation/v8"
@@ -20,6 +21,7 @@ func TestMiddlewareWrapperImpl_Wrap(t *testing.T) {
 		sf.RequestLogging,
 		sf.RequestMetrics,
 		sf.PanicTo500,
+		sf.RequestID,
 	}
 	useTls := false
 
@@ -145,3 +147,108 @@ func TestMiddlewareWrapperImpl_Wrap_PanicsAreHandled(t *testing.T) {
 		w.AssertExpectations(t)
 	}
 }
+
+func TestRegisterMiddleware(t *testing.T) {
+	const subSystem = "my-sub"
+	const name = "my-name"
+	logFactory := &mockLogFactory{}
+	log := &mockLogger{}
+	m := &mockMetrics{}
+	corsOptions := &sf.CORSOptions{}
+	var actualSubsystem, actualName string
+	metaFunc := func(*http.Request, sf.RouterParams) map[string]string {
+		return map[string]string{"tenant": "acme"}
+	}
+	handleCalled := false
+	handle := func(sf.WrappedResponseWriter, *http.Request, sf.RouterParams) {
+		handleCalled = true
+	}
+	w := &mockResponseWriter{}
+	r, _ := http.NewRequest("GET", "https://www.sf.com/some/url", nil)
+
+	logFactory.On("NewLogger", mock.Anything).Return(log)
+
+	// Act
+	middleware := sf.RegisterMiddleware("test-tenant", func(subsystem, name string, handler sf.Handle,
+		metaFunc sf.MetaFunc) sf.Handle {
+
+		actualSubsystem = subsystem
+		actualName = name
+
+		return func(w sf.WrappedResponseWriter, r *http.Request, p sf.RouterParams) {
+			if metaFunc(r, p)["tenant"] == "acme" {
+				handler(w, r, p)
+			}
+		}
+	})
+
+	sut := sf.NewMiddlewareWrapper(logFactory, m, corsOptions, sf.ServiceGlobals{})
+	actual := sut.Wrap(subSystem, name, middleware, handle, metaFunc)
+	actual(w, r, sf.RouterParams{})
+
+	lookup, exists := sf.LookupMiddleware("test-tenant")
+	assert.True(t, exists)
+	assert.Equal(t, middleware, lookup)
+	assert.Equal(t, subSystem, actualSubsystem)
+	assert.Equal(t, name, actualName)
+	assert.True(t, handleCalled)
+	assert.Panics(t, func() {
+		sf.RegisterMiddleware("test-tenant", nil)
+	})
+}
+
+func TestRegisterHTTPMiddleware(t *testing.T) {
+	logFactory := &mockLogFactory{}
+	log := &mockLogger{}
+	m := &mockMetrics{}
+	corsOptions := &sf.CORSOptions{}
+	handle := func(w sf.WrappedResponseWriter, _ *http.Request, _ sf.RouterParams) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "https://www.sf.com/some/url", nil)
+
+	logFactory.On("NewLogger", mock.Anything).Return(log)
+
+	// Act
+	middleware := sf.RegisterHTTPMiddleware("test-powered-by", func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Powered-By", "sf")
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	sut := sf.NewMiddlewareWrapper(logFactory, m, corsOptions, sf.ServiceGlobals{})
+	actual := sut.Wrap("my-sub", "my-name", middleware, handle, nil)
+	actual(sf.NewWrappedResponseWriter(w), r, sf.RouterParams{})
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "sf", w.Header().Get("X-Powered-By"))
+}
+
+func TestMiddlewareWrapperImpl_Wrap_CORSPreFlightStopsChain(t *testing.T) {
+	logFactory := &mockLogFactory{}
+	log := &mockLogger{}
+	m := &mockMetrics{}
+	corsOptions := &sf.CORSOptions{AllowedOrigins: []string{"*"}, AllowedMethods: []string{http.MethodGet}}
+	handleCalled := false
+	handle := func(sf.WrappedResponseWriter, *http.Request, sf.RouterParams) {
+		handleCalled = true
+	}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodOptions, "https://www.sf.com/some/url", nil)
+	r.Header.Set("Origin", "https://www.travix.com")
+	r.Header.Set("Access-Control-Request-Method", http.MethodGet)
+
+	logFactory.On("NewLogger", mock.Anything).Return(log)
+
+	sut := sf.NewMiddlewareWrapper(logFactory, m, corsOptions, sf.ServiceGlobals{})
+
+	// Act
+	actual := sut.Wrap("my-sub", "my-name", sf.CORS, handle, nil)
+	actual(sf.NewWrappedResponseWriter(w), r, sf.RouterParams{})
+
+	assert.False(t, handleCalled)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+}
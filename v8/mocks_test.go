@@ -6,7 +6,6 @@ import (
 	"time"
 
 	sf "github.com/Travix-International/go-servicefoundation/v8"
-	"github.com/julienschmidt/httprouter"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -211,9 +210,9 @@ type mockRouterFactory struct {
 	sf.RouterFactory
 }
 
-func (m *mockRouterFactory) NewRouter() *sf.Router {
+func (m *mockRouterFactory) NewRouter() sf.Router {
 	a := m.Called()
-	return a.Get(0).(*sf.Router)
+	return a.Get(0).(sf.Router)
 }
 
 /* sf.ServiceHandlerFactory mock */
@@ -224,10 +223,10 @@ type mockServiceHandlerFactory struct {
 }
 
 func (m *mockServiceHandlerFactory) Wrap(subsystem, name string, middlewares []sf.Middleware, handle sf.Handle,
-	metaFunc sf.MetaFunc) httprouter.Handle {
+	metaFunc sf.MetaFunc) sf.RouterHandle {
 
 	a := m.Called(subsystem, name, middlewares, handle, metaFunc)
-	return a.Get(0).(httprouter.Handle)
+	return a.Get(0).(sf.RouterHandle)
 }
 
 func (m *mockServiceHandlerFactory) NewHandlers() *sf.Handlers {
@@ -0,0 +1,194 @@
+package v8
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// ParamMissing is a ParamErrorKind indicating that a parameter has no value.
+	ParamMissing ParamErrorKind = "missing"
+	// ParamInvalid is a ParamErrorKind indicating that a parameter value could not be parsed.
+	ParamInvalid ParamErrorKind = "invalid"
+	// ParamNotAllowed is a ParamErrorKind indicating that a parameter value is not one of the allowed values.
+	ParamNotAllowed ParamErrorKind = "not allowed"
+
+	pathParamSource  = "path"
+	queryParamSource = "query"
+)
+
+type (
+	// ParamErrorKind is an enumeration to indicate why a parameter could not be accessed.
+	ParamErrorKind string
+
+	// ParamError is the error returned by the typed accessors of RouterParams and QueryParams.
+	ParamError struct {
+		Kind   ParamErrorKind
+		Source string
+		Name   string
+		Value  string
+		Err    error
+	}
+
+	// QueryParams is a struct that wraps the query-string values of a request and provides the same typed accessors
+	// as RouterParams.
+	QueryParams struct {
+		Values url.Values
+	}
+)
+
+// NewQueryParams creates and returns QueryParams for the query-string of the specified request.
+func NewQueryParams(r *http.Request) QueryParams {
+	return QueryParams{Values: r.URL.Query()}
+}
+
+// WriteBadRequest writes the specified error as an ErrorResponse with http status-code 400.
+func WriteBadRequest(w WrappedResponseWriter, r *http.Request, err error) {
+	writeErrorResponse(w, r, http.StatusBadRequest, err.Error())
+}
+
+/* ParamError implementation */
+
+func (e *ParamError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s parameter '%s' is %s: %v", e.Source, e.Name, e.Kind, e.Err)
+	}
+	return fmt.Sprintf("%s parameter '%s' is %s", e.Source, e.Name, e.Kind)
+}
+
+func (e *ParamError) Unwrap() error {
+	return e.Err
+}
+
+/* RouterParams accessors */
+
+// Int returns the value of the path parameter with the specified name as an int.
+func (p RouterParams) Int(name string) (int, error) {
+	return parseIntParam(pathParamSource, name, p.ByName(name))
+}
+
+// Int64 returns the value of the path parameter with the specified name as an int64.
+func (p RouterParams) Int64(name string) (int64, error) {
+	return parseInt64Param(pathParamSource, name, p.ByName(name))
+}
+
+// UUID returns the value of the path parameter with the specified name as a UUID.
+func (p RouterParams) UUID(name string) (uuid.UUID, error) {
+	return parseUUIDParam(pathParamSource, name, p.ByName(name))
+}
+
+// Enum returns the value of the path parameter with the specified name if it matches one of the allowed values.
+func (p RouterParams) Enum(name string, allowed ...string) (string, error) {
+	return parseEnumParam(pathParamSource, name, p.ByName(name), allowed)
+}
+
+// Duration returns the value of the path parameter with the specified name as a time.Duration.
+func (p RouterParams) Duration(name string) (time.Duration, error) {
+	return parseDurationParam(pathParamSource, name, p.ByName(name))
+}
+
+/* QueryParams accessors */
+
+// ByName returns the first value of the query parameter with the specified name.
+func (q QueryParams) ByName(name string) string {
+	return q.Values.Get(name)
+}
+
+// Has returns whether the query parameter with the specified name is present.
+func (q QueryParams) Has(name string) bool {
+	_, ok := q.Values[name]
+	return ok
+}
+
+// Int returns the value of the query parameter with the specified name as an int.
+func (q QueryParams) Int(name string) (int, error) {
+	return parseIntParam(queryParamSource, name, q.ByName(name))
+}
+
+// Int64 returns the value of the query parameter with the specified name as an int64.
+func (q QueryParams) Int64(name string) (int64, error) {
+	return parseInt64Param(queryParamSource, name, q.ByName(name))
+}
+
+// UUID returns the value of the query parameter with the specified name as a UUID.
+func (q QueryParams) UUID(name string) (uuid.UUID, error) {
+	return parseUUIDParam(queryParamSource, name, q.ByName(name))
+}
+
+// Enum returns the value of the query parameter with the specified name if it matches one of the allowed values.
+func (q QueryParams) Enum(name string, allowed ...string) (string, error) {
+	return parseEnumParam(queryParamSource, name, q.ByName(name), allowed)
+}
+
+// Duration returns the value of the query parameter with the specified name as a time.Duration.
+func (q QueryParams) Duration(name string) (time.Duration, error) {
+	return parseDurationParam(queryParamSource, name, q.ByName(name))
+}
+
+func parseIntParam(source, name, value string) (int, error) {
+	if value == "" {
+		return 0, &ParamError{Kind: ParamMissing, Source: source, Name: name}
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, &ParamError{Kind: ParamInvalid, Source: source, Name: name, Value: value, Err: err}
+	}
+	return i, nil
+}
+
+func parseInt64Param(source, name, value string) (int64, error) {
+	if value == "" {
+		return 0, &ParamError{Kind: ParamMissing, Source: source, Name: name}
+	}
+
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, &ParamError{Kind: ParamInvalid, Source: source, Name: name, Value: value, Err: err}
+	}
+	return i, nil
+}
+
+func parseUUIDParam(source, name, value string) (uuid.UUID, error) {
+	if value == "" {
+		return uuid.Nil, &ParamError{Kind: ParamMissing, Source: source, Name: name}
+	}
+
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, &ParamError{Kind: ParamInvalid, Source: source, Name: name, Value: value, Err: err}
+	}
+	return id, nil
+}
+
+func parseEnumParam(source, name, value string, allowed []string) (string, error) {
+	if value == "" {
+		return "", &ParamError{Kind: ParamMissing, Source: source, Name: name}
+	}
+
+	for _, a := range allowed {
+		if value == a {
+			return value, nil
+		}
+	}
+	return "", &ParamError{Kind: ParamNotAllowed, Source: source, Name: name, Value: value,
+		Err: fmt.Errorf("expected one of [%s]", strings.Join(allowed, ", "))}
+}
+
+func parseDurationParam(source, name, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, &ParamError{Kind: ParamMissing, Source: source, Name: name}
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, &ParamError{Kind: ParamInvalid, Source: source, Name: name, Value: value, Err: err}
+	}
+	return d, nil
+}
@@ -0,0 +1,97 @@
+package v8_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	sf "github.com/Travix-International/go-servicefoundation/v8"
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouterParams_TypedAccessors(t *testing.T) {
+	id := uuid.New()
+	sut := sf.RouterParams{Params: httprouter.Params{
+		{Key: "count", Value: "42"},
+		{Key: "big", Value: "9007199254740993"},
+		{Key: "id", Value: id.String()},
+		{Key: "kind", Value: "flights"},
+		{Key: "ttl", Value: "1m30s"},
+	}}
+
+	// Act
+	count, countErr := sut.Int("count")
+	big, bigErr := sut.Int64("big")
+	actualID, idErr := sut.UUID("id")
+	kind, kindErr := sut.Enum("kind", "hotels", "flights")
+	ttl, ttlErr := sut.Duration("ttl")
+
+	assert.Nil(t, countErr)
+	assert.Equal(t, 42, count)
+	assert.Nil(t, bigErr)
+	assert.Equal(t, int64(9007199254740993), big)
+	assert.Nil(t, idErr)
+	assert.Equal(t, id, actualID)
+	assert.Nil(t, kindErr)
+	assert.Equal(t, "flights", kind)
+	assert.Nil(t, ttlErr)
+	assert.Equal(t, 90*time.Second, ttl)
+}
+
+func TestRouterParams_TypedAccessors_Errors(t *testing.T) {
+	sut := sf.RouterParams{Params: httprouter.Params{
+		{Key: "count", Value: "many"},
+		{Key: "kind", Value: "cars"},
+	}}
+
+	// Act
+	_, missingErr := sut.Int("unknown")
+	_, invalidErr := sut.Int("count")
+	_, notAllowedErr := sut.Enum("kind", "hotels", "flights")
+
+	var paramErr *sf.ParamError
+	assert.True(t, errors.As(missingErr, &paramErr))
+	assert.Equal(t, sf.ParamMissing, paramErr.Kind)
+	assert.True(t, errors.As(invalidErr, &paramErr))
+	assert.Equal(t, sf.ParamInvalid, paramErr.Kind)
+	assert.Equal(t, "many", paramErr.Value)
+	assert.True(t, errors.As(notAllowedErr, &paramErr))
+	assert.Equal(t, sf.ParamNotAllowed, paramErr.Kind)
+	assert.Equal(t, "path parameter 'kind' is not allowed: expected one of [hotels, flights]", notAllowedErr.Error())
+}
+
+func TestQueryParams_TypedAccessors(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodGet, "https://www.sf.com/search?page=2&sort=desc&timeout=bad", nil)
+	sut := sf.NewQueryParams(r)
+
+	// Act
+	page, pageErr := sut.Int("page")
+	sort, sortErr := sut.Enum("sort", "asc", "desc")
+	_, timeoutErr := sut.Duration("timeout")
+	_, sizeErr := sut.Int64("size")
+
+	assert.Nil(t, pageErr)
+	assert.Equal(t, 2, page)
+	assert.Nil(t, sortErr)
+	assert.Equal(t, "desc", sort)
+	assert.EqualError(t, timeoutErr, `query parameter 'timeout' is invalid: time: invalid duration "bad"`)
+	assert.EqualError(t, sizeErr, "query parameter 'size' is missing")
+	assert.True(t, sut.Has("page"))
+	assert.False(t, sut.Has("size"))
+}
+
+func TestWriteBadRequest(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodGet, "https://www.sf.com/users/abc", nil)
+	w := &mockResponseWriter{}
+	_, err := sf.RouterParams{}.Int("id")
+
+	w.On("WriteResponse", r, http.StatusBadRequest, sf.ErrorResponse{Message: "path parameter 'id' is missing"}).Once()
+
+	// Act
+	sf.WriteBadRequest(w, r, err)
+
+	w.AssertExpectations(t)
+}
@@ -0,0 +1,208 @@
+package v8
+
+import (
+	"container/list"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRateLimitMaxKeys     = 10000
+	defaultRateLimitIdleTimeout = 10 * time.Minute
+)
+
+type (
+	// RateLimitKeyFunc is a function signature to determine the key a request is rate limited by.
+	RateLimitKeyFunc func(r *http.Request) string
+
+	// RateLimitResult contains the outcome of taking a token from a bucket.
+	RateLimitResult struct {
+		// Allowed indicates whether a token was available.
+		Allowed bool
+		// Remaining is the number of tokens left in the bucket.
+		Remaining int
+		// RetryAfter is the time until the next token is available.
+		RetryAfter time.Duration
+		// Reset is the time until the bucket is full again.
+		Reset time.Duration
+	}
+
+	// RateLimitStore is an interface for storing the token buckets used by the RateLimit middleware.
+	RateLimitStore interface {
+		// Take takes a token from the bucket with the specified key. Buckets hold up to burst tokens and are
+		// refilled with rate tokens per second.
+		Take(key string, rate float64, burst int) RateLimitResult
+	}
+
+	// RateLimitOptions contains properties used by the RateLimit middleware.
+	RateLimitOptions struct {
+		// Rate is the number of requests per second allowed per key on average.
+		Rate float64
+		// Burst is the maximum number of requests allowed per key at once. Default value is Rate, with a minimum
+		// of 1.
+		Burst int
+		// KeyFunc determines the key a request is rate limited by. Default value is RateLimitByIP.
+		KeyFunc RateLimitKeyFunc
+		// Store contains the token buckets. Default value is a new in-memory store.
+		Store RateLimitStore
+	}
+
+	memoryRateLimitStore struct {
+		mutex       sync.Mutex
+		buckets     map[string]*list.Element
+		lru         *list.List
+		maxKeys     int
+		idleTimeout time.Duration
+	}
+
+	tokenBucket struct {
+		key     string
+		tokens  float64
+		updated time.Time
+	}
+)
+
+// RateLimit returns a Middleware enumeration that limits the requests of a route using token buckets. Rejected
+// requests get http status-code 429. It panics when the rate is not positive or the burst is negative. Routes using the
+// returned Middleware share the store, which keeps separate buckets per route.
+func RateLimit(options RateLimitOptions) Middleware {
+	if options.Rate <= 0 || math.IsNaN(options.Rate) || math.IsInf(options.Rate, 0) {
+		panic(fmt.Errorf("Invalid rate limit rate: %v", options.Rate))
+	}
+	if options.Burst < 0 {
+		panic(fmt.Errorf("Invalid rate limit burst: %d", options.Burst))
+	}
+	if options.Burst == 0 {
+		options.Burst = int(math.Max(1, math.Ceil(options.Rate)))
+	}
+	if options.KeyFunc == nil {
+		options.KeyFunc = RateLimitByIP
+	}
+	if options.Store == nil {
+		options.Store = NewMemoryRateLimitStore(defaultRateLimitMaxKeys, defaultRateLimitIdleTimeout)
+	}
+
+	return registerMiddleware("", func(m *middlewareWrapperImpl, subsystem, name string, handler Handle,
+		_ MetaFunc) Handle {
+
+		return m.wrapWithRateLimit(subsystem, name, handler, options)
+	})
+}
+
+// RateLimitByIP is a RateLimitKeyFunc that limits requests by client IP address, as resolved by the RealIP
+// middleware.
+func RateLimitByIP(r *http.Request) string {
+	return ClientIP(r)
+}
+
+// RateLimitByHeader returns a RateLimitKeyFunc that limits requests by the value of the specified header, like an
+// API key. Requests without the header are limited by client IP address.
+func RateLimitByHeader(header string) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		if value := r.Header.Get(header); value != "" {
+			return header + ":" + value
+		}
+		return RateLimitByIP(r)
+	}
+}
+
+// NewMemoryRateLimitStore instantiates a new in-memory RateLimitStore implementation. It holds up to maxKeys buckets,
+// evicting the least recently used ones, and evicts buckets that have not been used for the idle timeout.
+func NewMemoryRateLimitStore(maxKeys int, idleTimeout time.Duration) RateLimitStore {
+	return &memoryRateLimitStore{
+		buckets:     make(map[string]*list.Element),
+		lru:         list.New(),
+		maxKeys:     maxKeys,
+		idleTimeout: idleTimeout,
+	}
+}
+
+/* RateLimitStore implementation */
+
+func (s *memoryRateLimitStore) Take(key string, rate float64, burst int) RateLimitResult {
+	now := time.Now()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var bucket *tokenBucket
+
+	if element, exists := s.buckets[key]; exists {
+		bucket = element.Value.(*tokenBucket)
+		bucket.tokens = math.Min(float64(burst), bucket.tokens+now.Sub(bucket.updated).Seconds()*rate)
+		s.lru.MoveToFront(element)
+		s.evict(now, 0)
+	} else {
+		s.evict(now, 1)
+		bucket = &tokenBucket{key: key, tokens: float64(burst)}
+		s.buckets[key] = s.lru.PushFront(bucket)
+	}
+	bucket.updated = now
+
+	result := RateLimitResult{}
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - bucket.tokens) / rate)
+	}
+
+	result.Remaining = int(bucket.tokens)
+	result.Reset = secondsToDuration((float64(burst) - bucket.tokens) / rate)
+	return result
+}
+
+// evict removes the least recently used buckets that are idle or exceed the maximum number of keys, keeping room for
+// the specified number of new buckets.
+func (s *memoryRateLimitStore) evict(now time.Time, room int) {
+	for element := s.lru.Back(); element != nil; element = s.lru.Back() {
+		bucket := element.Value.(*tokenBucket)
+
+		if len(s.buckets)+room <= s.maxKeys && now.Sub(bucket.updated) < s.idleTimeout {
+			return
+		}
+
+		s.lru.Remove(element)
+		delete(s.buckets, bucket.key)
+	}
+}
+
+func (m *middlewareWrapperImpl) wrapWithRateLimit(subsystem, name string, handler Handle,
+	options RateLimitOptions) Handle {
+
+	keyPrefix := subsystem + "/" + name + "/"
+	limit := strconv.Itoa(options.Burst)
+
+	return func(w WrappedResponseWriter, r *http.Request, p RouterParams) {
+		result := options.Store.Take(keyPrefix+options.KeyFunc(r), options.Rate, options.Burst)
+
+		w.Header().Set("X-RateLimit-Limit", limit)
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.Header().Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+		if !result.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			writeErrorResponse(w, r, http.StatusTooManyRequests, "Too many requests")
+
+			labels, values := m.getLabelsAndValues(subsystem, name, w, r)
+			m.metrics.CountLabels("", "http_requests_ratelimited_total", "Total requests rejected by rate limiting.",
+				labels, values)
+			return
+		}
+
+		handler(w, r, p)
+	}
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,92 @@
+package v8_test
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sf "github.com/Travix-International/go-servicefoundation/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMiddlewareWrapperImpl_Wrap_RateLimit(t *testing.T) {
+	logFactory := &mockLogFactory{}
+	log := &mockLogger{}
+	m := &mockMetrics{}
+	var calls int
+	handle := func(sf.WrappedResponseWriter, *http.Request, sf.RouterParams) {
+		calls++
+	}
+	middleware := sf.RateLimit(sf.RateLimitOptions{Rate: 0.001, Burst: 2})
+
+	logFactory.On("NewLogger", mock.Anything).Return(log)
+	m.On("CountLabels", "", "http_requests_ratelimited_total", mock.Anything,
+		mock.Anything, mock.MatchedBy(func(values []string) bool {
+			return values[3] == "429" && values[5] == "my-name" && values[7] == "my-sub"
+		})).Once()
+
+	sut := sf.NewMiddlewareWrapper(logFactory, m, &sf.CORSOptions{}, sf.ServiceGlobals{})
+	wrapped := sut.Wrap("my-sub", "my-name", middleware, handle, nil)
+
+	// Act
+	var recorders []*httptest.ResponseRecorder
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "https://www.sf.com/some/url", nil)
+		wrapped(sf.NewWrappedResponseWriter(w), r, sf.RouterParams{})
+		recorders = append(recorders, w)
+	}
+
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, http.StatusOK, recorders[0].Code)
+	assert.Equal(t, "2", recorders[0].Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", recorders[0].Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "0", recorders[1].Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, http.StatusTooManyRequests, recorders[2].Code)
+	assert.Equal(t, "1000", recorders[2].Header().Get("Retry-After"))
+	m.AssertExpectations(t)
+}
+
+func TestRateLimit_InvalidOptions(t *testing.T) {
+	scenarios := []sf.RateLimitOptions{
+		{},
+		{Rate: -1},
+		{Rate: math.Inf(1)},
+		{Rate: 1, Burst: -1},
+	}
+
+	for i, scenario := range scenarios {
+		assert.Panics(t, func() { sf.RateLimit(scenario) }, "Scenario %d", i)
+	}
+}
+
+func TestRateLimitByHeader(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "https://www.sf.com/some/url", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	sut := sf.RateLimitByHeader("X-Api-Key")
+
+	// Act
+	withoutHeader := sut(r)
+	r.Header.Set("X-Api-Key", "secret")
+	withHeader := sut(r)
+
+	assert.Equal(t, "10.0.0.1", withoutHeader)
+	assert.Equal(t, "X-Api-Key:secret", withHeader)
+}
+
+func TestMemoryRateLimitStore_Take_EvictsLeastRecentlyUsed(t *testing.T) {
+	sut := sf.NewMemoryRateLimitStore(2, time.Hour)
+
+	// Act
+	sut.Take("a", 0.001, 2)
+	sut.Take("b", 0.001, 2)
+	sut.Take("c", 0.001, 2)
+	evicted := sut.Take("a", 0.001, 2)
+	kept := sut.Take("c", 0.001, 2)
+
+	assert.Equal(t, 1, evicted.Remaining)
+	assert.Equal(t, 0, kept.Remaining)
+}
@@ -0,0 +1,215 @@
+package v8
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type (
+	// RealIPOptions contains properties used by the RealIP middleware.
+	RealIPOptions struct {
+		// TrustedProxies contains the IP addresses and CIDRs of the proxies whose forwarding headers are trusted, like
+		// "10.0.0.0/8".
+		TrustedProxies []string
+	}
+
+	// clientInfo contains the client address and scheme resolved by the RealIP middleware.
+	clientInfo struct {
+		ip     string
+		scheme string
+	}
+
+	clientInfoKey struct{}
+)
+
+// RealIP returns a Middleware enumeration that resolves the client IP address and scheme from the Forwarded (RFC
+// 7239), X-Forwarded-For, X-Real-IP and X-Forwarded-Proto headers, when the request comes from one of the trusted
+// proxies. The first address from the right that is not a trusted proxy is the client address. The result is available
+// through ClientIP and ClientScheme. The trusted proxies are parsed once; it panics when one is not a valid IP address
+// or CIDR.
+func RealIP(options RealIPOptions) Middleware {
+	trusted, err := ParseCIDRs(options.TrustedProxies)
+	if err != nil {
+		panic(err)
+	}
+
+	return registerMiddleware("", func(m *middlewareWrapperImpl, subsystem, name string, handler Handle,
+		_ MetaFunc) Handle {
+
+		return m.wrapWithRealIP(handler, trusted)
+	})
+}
+
+// ClientIP returns the client IP address resolved by the RealIP middleware, or the remote address of the request when
+// absent.
+func ClientIP(r *http.Request) string {
+	if info, ok := r.Context().Value(clientInfoKey{}).(clientInfo); ok {
+		return info.ip
+	}
+	return remoteIP(r)
+}
+
+// ClientScheme returns the scheme used by the client as resolved by the RealIP middleware, or the scheme of the
+// request when absent.
+func ClientScheme(r *http.Request) string {
+	if info, ok := r.Context().Value(clientInfoKey{}).(clientInfo); ok && info.scheme != "" {
+		return info.scheme
+	}
+	return requestScheme(r)
+}
+
+// ParseCIDRs parses IP addresses and CIDRs, like "192.168.1.10" or "10.0.0.0/8". Addresses are converted to a CIDR
+// matching only that address.
+func ParseCIDRs(values []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("Invalid IP address: %s", value)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CIDR: %s", value)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+func (m *middlewareWrapperImpl) wrapWithRealIP(handler Handle, trusted []*net.IPNet) Handle {
+	return func(w WrappedResponseWriter, r *http.Request, p RouterParams) {
+		info := resolveClient(r, trusted)
+		handler(w, r.WithContext(context.WithValue(r.Context(), clientInfoKey{}, info)), p)
+	}
+}
+
+// resolveClient walks the forwarding chain from the right, skipping trusted proxies.
+func resolveClient(r *http.Request, trusted []*net.IPNet) clientInfo {
+	info := clientInfo{ip: remoteIP(r), scheme: requestScheme(r)}
+	if !containsIP(trusted, info.ip) {
+		return info
+	}
+
+	chain, proto := forwardedChain(r.Header)
+	if len(chain) == 0 {
+		if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(realIP) != nil {
+			chain = []string{realIP}
+		}
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		ip := chain[i]
+		if net.ParseIP(ip) == nil {
+			// Obfuscated or invalid addresses can not be followed any further.
+			break
+		}
+		info.ip = ip
+		if !containsIP(trusted, ip) {
+			break
+		}
+	}
+
+	if proto == "http" || proto == "https" {
+		info.scheme = proto
+	}
+	return info
+}
+
+// forwardedChain returns the addresses in the Forwarded header, or else the X-Forwarded-For header, and the protocol
+// reported by the proxy closest to the service.
+func forwardedChain(h http.Header) ([]string, string) {
+	var chain []string
+	var proto string
+
+	if values := h.Values("Forwarded"); len(values) > 0 {
+		for _, element := range strings.Split(strings.Join(values, ","), ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, value, _ := strings.Cut(strings.TrimSpace(pair), "=")
+				value = strings.Trim(value, `"`)
+
+				switch strings.ToLower(key) {
+				case "for":
+					chain = append(chain, forwardedNodeIP(value))
+				case "proto":
+					proto = strings.ToLower(value)
+				}
+			}
+		}
+		return chain, proto
+	}
+
+	for _, value := range h.Values("X-Forwarded-For") {
+		for _, ip := range strings.Split(value, ",") {
+			chain = append(chain, strings.TrimSpace(ip))
+		}
+	}
+	if values := h.Values("X-Forwarded-Proto"); len(values) > 0 {
+		protos := strings.Split(values[len(values)-1], ",")
+		proto = strings.ToLower(strings.TrimSpace(protos[len(protos)-1]))
+	}
+	return chain, proto
+}
+
+// forwardedNodeIP returns the IP address of a Forwarded node, like 192.0.2.43:47011 or [2001:db8::1]:4711.
+func forwardedNodeIP(node string) string {
+	if strings.HasPrefix(node, "[") {
+		if end := strings.Index(node, "]"); end > 0 {
+			return node[1:end]
+		}
+		return node
+	}
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		return host
+	}
+	return node
+}
+
+func containsIP(nets []*net.IPNet, value string) bool {
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func requestScheme(r *http.Request) string {
+	if r.URL != nil && r.URL.Scheme != "" {
+		return r.URL.Scheme
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
@@ -0,0 +1,140 @@
+package v8_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	sf "github.com/Travix-International/go-servicefoundation/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMiddlewareWrapperImpl_Wrap_RealIP(t *testing.T) {
+	scenarios := []struct {
+		remoteAddr     string
+		headers        map[string]string
+		expectedIP     string
+		expectedScheme string
+	}{
+		{"203.0.113.7:1234", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "203.0.113.7", "http"},
+		{"10.0.0.1:1234", nil, "10.0.0.1", "http"},
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "198.51.100.1", "X-Forwarded-Proto": "https"},
+			"198.51.100.1", "https"},
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.1, 10.0.0.2"}, "198.51.100.1",
+			"http"},
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"}, "10.0.0.3", "http"},
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "garbage, 10.0.0.2"}, "10.0.0.2", "http"},
+		{"10.0.0.1:1234", map[string]string{"X-Real-IP": "198.51.100.2"}, "198.51.100.2", "http"},
+		{"10.0.0.1:1234", map[string]string{"Forwarded": `for=198.51.100.3;proto=https, for="10.0.0.2:8080"`},
+			"198.51.100.3", "https"},
+		{"10.0.0.1:1234", map[string]string{"Forwarded": `for="[2001:db8::1]:4711"`}, "2001:db8::1", "http"},
+		{"10.0.0.1:1234", map[string]string{"Forwarded": "for=_hidden, for=198.51.100.4",
+			"X-Forwarded-For": "1.2.3.4"}, "198.51.100.4", "http"},
+		{"[::1]:1234", map[string]string{"X-Forwarded-For": "198.51.100.5"}, "198.51.100.5", "http"},
+	}
+
+	for i, scenario := range scenarios {
+		logFactory := &mockLogFactory{}
+		log := &mockLogger{}
+		m := &mockMetrics{}
+		var actualIP, actualScheme, actualKey string
+		handle := func(w sf.WrappedResponseWriter, r *http.Request, _ sf.RouterParams) {
+			actualIP = sf.ClientIP(r)
+			actualScheme = sf.ClientScheme(r)
+			actualKey = sf.RateLimitByIP(r)
+		}
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/path", nil)
+		r.RemoteAddr = scenario.remoteAddr
+		for key, value := range scenario.headers {
+			r.Header.Set(key, value)
+		}
+
+		logFactory.On("NewLogger", mock.Anything).Return(log)
+
+		sut := sf.NewMiddlewareWrapper(logFactory, m, &sf.CORSOptions{}, sf.ServiceGlobals{})
+		mw := sf.RealIP(sf.RealIPOptions{TrustedProxies: []string{"10.0.0.0/8", "::1"}})
+
+		// Act
+		sut.Wrap("my-sub", "my-name", mw, handle, nil)(sf.NewWrappedResponseWriter(w), r, sf.RouterParams{})
+
+		assert.Equal(t, scenario.expectedIP, actualIP, "Scenario %d", i)
+		assert.Equal(t, scenario.expectedScheme, actualScheme, "Scenario %d", i)
+		assert.Equal(t, scenario.expectedIP, actualKey, "Scenario %d", i)
+	}
+}
+
+func TestClientIP_WithoutRealIP(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "https://www.sf.com/path", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+	// Act
+	ip := sf.ClientIP(r)
+
+	assert.Equal(t, "10.0.0.1", ip)
+	assert.Equal(t, "https", sf.ClientScheme(r))
+}
+
+func TestParseCIDRs(t *testing.T) {
+	scenarios := []struct {
+		values        []string
+		expected      []string
+		expectedError bool
+	}{
+		{[]string{"10.0.0.0/8", " 192.168.1.10 ", "", "2001:db8::/32", "::1"},
+			[]string{"10.0.0.0/8", "192.168.1.10/32", "2001:db8::/32", "::1/128"}, false},
+		{[]string{"10.0.0.0/33"}, nil, true},
+		{[]string{"not-an-ip"}, nil, true},
+	}
+
+	for i, scenario := range scenarios {
+		// Act
+		nets, err := sf.ParseCIDRs(scenario.values)
+
+		assert.Equal(t, scenario.expectedError, err != nil, "Scenario %d", i)
+		var actual []string
+		for _, n := range nets {
+			actual = append(actual, n.String())
+		}
+		assert.Equal(t, scenario.expected, actual, "Scenario %d", i)
+	}
+}
+
+func TestRealIP_InvalidTrustedProxy(t *testing.T) {
+	assert.Panics(t, func() {
+		sf.RealIP(sf.RealIPOptions{TrustedProxies: []string{"10.0.0.0/99"}})
+	})
+}
+
+func TestMiddlewareWrapperImpl_Wrap_RealIPLogsClientIP(t *testing.T) {
+	logFactory := &mockLogFactory{}
+	log := &mockLogger{}
+	m := &mockMetrics{}
+	handle := func(w sf.WrappedResponseWriter, _ *http.Request, _ sf.RouterParams) {
+		w.WriteHeader(http.StatusOK)
+	}
+	metaFunc := func(_ *http.Request, _ sf.RouterParams) map[string]string {
+		return make(map[string]string)
+	}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "http://www.sf.com/path", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+	r.Header.Set("X-Forwarded-Proto", "https")
+
+	logFactory.On("NewLogger", mock.Anything).Return(log)
+	log.On("Info", mock.Anything, mock.Anything, mock.Anything).Return()
+
+	sut := sf.NewMiddlewareWrapper(logFactory, m, &sf.CORSOptions{}, sf.ServiceGlobals{})
+	logged := sut.Wrap("my-sub", "my-name", sf.RequestLogging, handle, metaFunc)
+	mw := sf.RealIP(sf.RealIPOptions{TrustedProxies: []string{"10.0.0.0/8"}})
+
+	// Act
+	sut.Wrap("my-sub", "my-name", mw, logged, metaFunc)(sf.NewWrappedResponseWriter(w), r, sf.RouterParams{})
+
+	logFactory.AssertCalled(t, "NewLogger", mock.MatchedBy(func(meta map[string]string) bool {
+		return meta["entry.client.ip"] == "198.51.100.1" && meta["entry.http.scheme"] == "https"
+	}))
+}
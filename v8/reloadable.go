@@ -0,0 +1,67 @@
+package v8
+
+import (
+	"sync"
+	"time"
+)
+
+// reloadableSource holds a value loaded from a source, like credentials or IP addresses read from a file. With a
+// positive reload interval, the value is reloaded when it is older than the interval; otherwise only Reload loads it
+// again.
+type reloadableSource struct {
+	mutex          sync.RWMutex
+	reloading      sync.Mutex
+	load           func() (interface{}, error)
+	value          interface{}
+	reloadInterval time.Duration
+	loaded         time.Time
+}
+
+func newReloadableSource(load func() (interface{}, error), reloadInterval time.Duration) (*reloadableSource, error) {
+	s := &reloadableSource{
+		load:           load,
+		reloadInterval: reloadInterval,
+	}
+	return s, s.Reload()
+}
+
+// get returns the value, reloading it first when it is stale. Only one caller reloads at a time; the others get the
+// current value meanwhile.
+func (s *reloadableSource) get() interface{} {
+	value, stale := s.current()
+	if !stale || !s.reloading.TryLock() {
+		return value
+	}
+	defer s.reloading.Unlock()
+
+	// Another caller may have reloaded the value since it was read.
+	if value, stale = s.current(); stale && s.Reload() == nil {
+		value, _ = s.current()
+	}
+	return value
+}
+
+// current returns the value and whether it is older than the reload interval.
+func (s *reloadableSource) current() (interface{}, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.value, s.reloadInterval > 0 && time.Since(s.loaded) >= s.reloadInterval
+}
+
+// Reload loads the value from the source. When loading fails, the current value is kept.
+func (s *reloadableSource) Reload() error {
+	value, err := s.load()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Also postpone the next reload after a failure, so a broken source is not read on every request.
+	s.loaded = time.Now()
+
+	if err != nil {
+		return err
+	}
+	s.value = value
+	return nil
+}
@@ -0,0 +1,65 @@
+package v8
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// RequestIDHeader is the name of the http header containing the request ID.
+	RequestIDHeader = "X-Request-ID"
+	// CorrelationIDHeader is the name of the alternative http header containing the request ID.
+	CorrelationIDHeader = "X-Correlation-ID"
+
+	maxRequestIDLength = 128
+)
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the request ID stored by the RequestID middleware, or an empty string when absent.
+// Use it to propagate the ID to outbound requests.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func (m *middlewareWrapperImpl) wrapWithRequestID(subsystem, name string, handler Handle) Handle {
+	return func(w WrappedResponseWriter, r *http.Request, p RouterParams) {
+		header := RequestIDHeader
+		id := r.Header.Get(RequestIDHeader)
+
+		if id == "" {
+			header = CorrelationIDHeader
+			id = r.Header.Get(CorrelationIDHeader)
+		}
+
+		if !isValidRequestID(id) {
+			header = RequestIDHeader
+			id = uuid.New().String()
+		}
+
+		w.Header().Set(header, id)
+		AddRequestMeta(r, "entry.correlationid", id)
+
+		handler(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)), p)
+	}
+}
+
+// isValidRequestID returns whether an incoming request ID is safe to echo and log.
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package v8_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	sf "github.com/Travix-International/go-servicefoundation/v8"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMiddlewareWrapperImpl_Wrap_RequestID(t *testing.T) {
+	scenarios := []struct {
+		header         string
+		value          string
+		expectedHeader string
+		generated      bool
+	}{
+		{"X-Request-ID", "abc-123", "X-Request-ID", false},
+		{"X-Correlation-ID", "def-456", "X-Correlation-ID", false},
+		{"X-Request-ID", "", "X-Request-ID", true},
+		{"X-Request-ID", "not valid\n", "X-Request-ID", true},
+		{"X-Request-ID", strings.Repeat("a", 129), "X-Request-ID", true},
+	}
+
+	for i, scenario := range scenarios {
+		logFactory := &mockLogFactory{}
+		log := &mockLogger{}
+		m := &mockMetrics{}
+		var actual string
+		handle := func(_ sf.WrappedResponseWriter, r *http.Request, _ sf.RouterParams) {
+			actual = sf.RequestIDFromContext(r.Context())
+		}
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "https://www.sf.com/some/url", nil)
+		r.Header.Set(scenario.header, scenario.value)
+
+		logFactory.On("NewLogger", mock.Anything).Return(log)
+
+		sut := sf.NewMiddlewareWrapper(logFactory, m, &sf.CORSOptions{}, sf.ServiceGlobals{})
+
+		// Act
+		sut.Wrap("my-sub", "my-name", sf.RequestID, handle, nil)(sf.NewWrappedResponseWriter(w), r, sf.RouterParams{})
+
+		assert.Equal(t, actual, w.Header().Get(scenario.expectedHeader), "Scenario %d", i)
+		if scenario.generated {
+			_, err := uuid.Parse(actual)
+			assert.Nil(t, err, "Scenario %d", i)
+		} else {
+			assert.Equal(t, scenario.value, actual, "Scenario %d", i)
+		}
+	}
+}
+
+func TestMiddlewareWrapperImpl_Wrap_RequestIDIsLogged(t *testing.T) {
+	logFactory := &mockLogFactory{}
+	log := &mockLogger{}
+	m := &mockMetrics{}
+	handle := func(sf.WrappedResponseWriter, *http.Request, sf.RouterParams) {
+		panic("whoa")
+	}
+	metaFunc := func(*http.Request, sf.RouterParams) map[string]string {
+		return make(map[string]string)
+	}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "https://www.sf.com/some/url", nil)
+	r.Header.Set(sf.RequestIDHeader, "abc-123")
+	var actual []string
+
+	logFactory.On("NewLogger", mock.Anything).Run(func(args mock.Arguments) {
+		meta := args.Get(0).(map[string]string)
+		actual = append(actual, meta["entry.correlationid"])
+	}).Return(log)
+	log.On("Error", "PanicAutorecover", mock.Anything, mock.Anything).Once()
+
+	mw := sf.NewMiddlewareWrapper(logFactory, m, &sf.CORSOptions{}, sf.ServiceGlobals{})
+	sut := sf.NewServiceHandlerFactory(mw, &mockVersionBuilder{}, &mockServiceStateReader{}, func(int) {})
+	actual = nil
+
+	// Act
+	sut.Wrap("my-sub", "my-name", []sf.Middleware{sf.RequestID, sf.PanicTo500}, handle, metaFunc)(w, r, sf.RouterParams{})
+
+	assert.Equal(t, []string{"abc-123"}, actual)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	log.AssertExpectations(t)
+}
@@ -0,0 +1,445 @@
+package v8
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMaxLoggedBodyBytes = 4096
+
+	// RedactedValue replaces redacted headers and fields in the log meta.
+	RedactedValue = "[REDACTED]"
+)
+
+// defaultRedactedHeaders contains the headers that are redacted by default.
+var defaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", APIKeyHeader}
+
+// defaultLoggedBodyTypes contains the content types of bodies captured by default. Entries ending with a slash match
+// all subtypes.
+var defaultLoggedBodyTypes = []string{
+	"text/",
+	ContentTypeJSON,
+	ContentTypeXML,
+	"application/problem+json",
+	"application/x-www-form-urlencoded",
+}
+
+type (
+	// RequestLoggingOptions contains properties used by the RequestLogging middleware.
+	RequestLoggingOptions struct {
+		// LogBodies adds the request headers and the request and response bodies to the log meta, as
+		// entry.http.request.header.*, entry.http.request.body and entry.http.response.body.
+		LogBodies bool
+		// BodySampleRate is the fraction of requests, between 0 and 1, whose bodies are logged. Default value is 1.
+		BodySampleRate float64
+		// MaxBodyBytes is the maximum number of bytes logged per body. Default value is 4096.
+		MaxBodyBytes int
+		// BodyContentTypes contains the content types of the bodies to log. Entries ending with a slash, like
+		// "text/", match all subtypes. Default value contains text, JSON, XML and form types.
+		BodyContentTypes []string
+		// RedactHeaders contains the headers whose values are replaced by RedactedValue. Default value contains
+		// Authorization, Proxy-Authorization, Cookie, Set-Cookie and X-Api-Key.
+		RedactHeaders []string
+		// RedactFields contains the JSON field paths whose values are replaced by RedactedValue, like "password" or
+		// "card.number". A "*" segment matches any field, and arrays are traversed. Single segment paths also apply to
+		// form fields.
+		RedactFields []string
+		// Format logs a single AccessLog message per request, formatted by the AccessLogFormatter, like
+		// CombinedLogFormat, JSONLogFormat or ECSLogFormat. Default value is nil, which logs an ApiRequest and an
+		// ApiResponse message.
+		Format AccessLogFormatter
+		// SampleRate is the fraction of successful requests, between 0 and 1, that are logged. Failed requests, with a
+		// status code of 400 or higher, and slow requests are always logged. Default value is 1.
+		SampleRate float64
+		// SlowThreshold is the duration above which requests are always logged. Default value is 0, which disables it.
+		SlowThreshold time.Duration
+		// ExcludePaths contains the paths of successful requests that are not logged, like "/service/readiness".
+		// Entries ending with an asterisk, like "/service/*", match all paths starting with the prefix.
+		ExcludePaths []string
+		// MaxPerSecond is the maximum number of successful requests logged per second for the route. Default value is
+		// 0, which is unlimited.
+		MaxPerSecond float64
+	}
+
+	// requestLogger logs requests and responses using the normalized options.
+	requestLogger struct {
+		options       RequestLoggingOptions
+		bodyTypes     map[string]bool
+		redactHeaders map[string]bool
+		redactFields  [][]string
+		// filtered is set when the options drop some requests, so the decision is made after the request finishes.
+		filtered bool
+		limiter  RateLimitStore
+		burst    int
+	}
+
+	// bodyCaptureWriter keeps the start of the response body for logging.
+	bodyCaptureWriter struct {
+		WrappedResponseWriter
+		buf bytes.Buffer
+		max int
+	}
+)
+
+// RequestLoggingWith returns a Middleware enumeration that logs the incoming request and response times using the
+// specified options. Each route gets its own sampling state, so MaxPerSecond applies per route.
+func RequestLoggingWith(options RequestLoggingOptions) Middleware {
+	return registerRequestMetaMiddleware(func(m *middlewareWrapperImpl, subsystem, name string, handler Handle,
+		metaFunc MetaFunc) Handle {
+
+		return m.wrapWithRequestLogging(subsystem, name, handler, metaFunc, options)
+	})
+}
+
+func newRequestLogger(options RequestLoggingOptions) *requestLogger {
+	if options.BodySampleRate <= 0 {
+		options.BodySampleRate = 1
+	}
+	if options.MaxBodyBytes <= 0 {
+		options.MaxBodyBytes = defaultMaxLoggedBodyBytes
+	}
+	if options.BodyContentTypes == nil {
+		options.BodyContentTypes = defaultLoggedBodyTypes
+	}
+	if options.RedactHeaders == nil {
+		options.RedactHeaders = defaultRedactedHeaders
+	}
+
+	l := &requestLogger{
+		options:       options,
+		bodyTypes:     make(map[string]bool),
+		redactHeaders: make(map[string]bool),
+	}
+	for _, t := range options.BodyContentTypes {
+		l.bodyTypes[strings.ToLower(t)] = true
+	}
+	for _, header := range options.RedactHeaders {
+		l.redactHeaders[strings.ToLower(header)] = true
+	}
+	for _, field := range options.RedactFields {
+		l.redactFields = append(l.redactFields, strings.Split(field, "."))
+	}
+	if options.MaxPerSecond > 0 {
+		l.limiter = NewMemoryRateLimitStore(1, time.Hour)
+		l.burst = int(math.Ceil(options.MaxPerSecond))
+	}
+	l.filtered = options.SampleRate > 0 && options.SampleRate < 1 || options.SlowThreshold > 0 ||
+		len(options.ExcludePaths) > 0 || l.limiter != nil
+	return l
+}
+
+func (m *middlewareWrapperImpl) wrapWithRequestLogging(subsystem, name string, handler Handle, metaFunc MetaFunc,
+	options RequestLoggingOptions) Handle {
+
+	l := newRequestLogger(options)
+
+	return func(w WrappedResponseWriter, r *http.Request, p RouterParams) {
+		logBodies := l.options.LogBodies && rand.Float64() < l.options.BodySampleRate
+		format := l.options.Format
+
+		meta := metaFunc(r, p)
+		var fields map[string]string
+		if format != nil {
+			fields = copyMeta(meta)
+		}
+
+		if logBodies {
+			l.addRequestMeta(r, meta)
+		}
+		var startMeta map[string]string
+		var startMessage string
+		if format == nil {
+			startMeta = l.redactMeta(m.getMeta(subsystem, name, nil, r, p, meta))
+			startMessage = m.getRequestStartMessage(r, p, meta)
+
+			if l.filtered {
+				// The ApiRequest message is postponed until it is known whether the request is logged.
+				startMeta = copyMeta(startMeta)
+			} else {
+				m.logFactory.NewLogger(startMeta).Info("ApiRequest", startMessage)
+			}
+		}
+
+		start := time.Now()
+		hw := w
+
+		var bytesIn *byteCountReader
+		var bytesOut *byteCountWriter
+		if format != nil {
+			if r.Body != nil {
+				bytesIn = &byteCountReader{ReadCloser: r.Body}
+				r.Body = bytesIn
+			}
+			bytesOut = &byteCountWriter{WrappedResponseWriter: hw}
+			hw = NewWrappedResponseWriter(bytesOut)
+		}
+
+		var capture *bodyCaptureWriter
+		if logBodies {
+			capture = &bodyCaptureWriter{WrappedResponseWriter: hw, max: l.options.MaxBodyBytes + 1}
+			hw = NewWrappedResponseWriter(capture)
+		}
+
+		handler(hw, r, p)
+
+		if l.filtered {
+			decision := l.decide(name, w.Status(), time.Since(start), r)
+			m.metrics.CountLabels("", "http_request_logs_total", "Total requests by request logging decision.",
+				[]string{"handler", "decision"}, []string{name, decision})
+
+			if !strings.HasPrefix(decision, "logged") {
+				return
+			}
+			if format == nil {
+				m.logFactory.NewLogger(startMeta).Info("ApiRequest", startMessage)
+			}
+		}
+
+		if capture != nil {
+			l.addBodyMeta(meta, "http.response.body", w.Header().Get(ContentTypeHeader), capture.buf.Bytes())
+		}
+
+		elapsedMs := float64(time.Since(start).Nanoseconds()/int64(time.Microsecond)) / 1000.0
+		durationMs := strconv.FormatFloat(elapsedMs, 'f', 3, 64)
+
+		meta["entry.duration"] = durationMs
+		meta = l.redactMeta(m.getMeta(subsystem, name, w, r, p, meta))
+
+		if format == nil {
+			log := m.logFactory.NewLogger(meta)
+			log.Info("ApiResponse", m.getRequestEndMessage(w, r, p, meta, durationMs))
+			return
+		}
+
+		var in int64
+		if bytesIn != nil {
+			in = bytesIn.n
+		}
+		copyRequestMeta(r, fields)
+
+		message, formatMeta := format.Format(newAccessLogEntry(r, w.Status(), start, in, bytesOut.n, meta, fields))
+		m.logFactory.NewLogger(formatMeta).Info("AccessLog", message)
+	}
+}
+
+func (m *middlewareWrapperImpl) getRequestStartMessage(r *http.Request, p RouterParams, meta map[string]string) string {
+	return fmt.Sprintf("%s %s", r.Method, meta["entry.http.url"])
+}
+
+func (m *middlewareWrapperImpl) getRequestEndMessage(w WrappedResponseWriter, r *http.Request, p RouterParams, meta map[string]string, durationMs string) string {
+	status := strconv.Itoa(w.Status())
+	contentType := w.Header().Get("content-type")
+
+	return fmt.Sprintf("%s %s finished. Duration: %sms. Status: %s, ContentType: %s",
+		r.Method,
+		meta["entry.http.url"],
+		durationMs,
+		status,
+		contentType,
+	)
+}
+
+// decide returns whether a finished request is logged, as logged, logged_error or logged_slow, or why it is
+// dropped, as excluded, sampled_out or rate_limited.
+func (l *requestLogger) decide(name string, status int, duration time.Duration, r *http.Request) string {
+	switch {
+	case status >= http.StatusBadRequest:
+		return "logged_error"
+	case l.options.SlowThreshold > 0 && duration >= l.options.SlowThreshold:
+		return "logged_slow"
+	case l.excluded(r.URL.Path):
+		return "excluded"
+	case l.options.SampleRate > 0 && rand.Float64() >= l.options.SampleRate:
+		return "sampled_out"
+	case l.limiter != nil && !l.limiter.Take(name, l.options.MaxPerSecond, l.burst).Allowed:
+		return "rate_limited"
+	}
+	return "logged"
+}
+
+// excluded returns whether the path matches one of the excluded paths.
+func (l *requestLogger) excluded(path string) bool {
+	for _, excluded := range l.options.ExcludePaths {
+		if prefix := strings.TrimSuffix(excluded, "*"); prefix != excluded {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		} else if path == excluded {
+			return true
+		}
+	}
+	return false
+}
+
+func copyMeta(meta map[string]string) map[string]string {
+	result := make(map[string]string, len(meta))
+	for key, value := range meta {
+		result[key] = value
+	}
+	return result
+}
+
+// addRequestMeta adds the request headers and the start of the request body to the meta. The body is restored, so
+// the handler can read it completely.
+func (l *requestLogger) addRequestMeta(r *http.Request, meta map[string]string) {
+	for key := range r.Header {
+		meta["entry.http.request.header."+strings.ToLower(key)] = r.Header.Get(key)
+	}
+
+	contentType := r.Header.Get(ContentTypeHeader)
+	if r.Body == nil || r.Body == http.NoBody || !l.loggable(contentType) {
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, int64(l.options.MaxBodyBytes)+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+
+	if err == nil {
+		l.addBodyMeta(meta, "http.request.body", contentType, body)
+	}
+}
+
+// addBodyMeta adds the redacted body to the meta. Bodies larger than the maximum are truncated; when they need
+// redaction, they are omitted since they can not be parsed.
+func (l *requestLogger) addBodyMeta(meta map[string]string, key, contentType string, body []byte) {
+	if len(body) == 0 || !l.loggable(contentType) {
+		return
+	}
+
+	truncated := len(body) > l.options.MaxBodyBytes
+	if truncated {
+		body = body[:l.options.MaxBodyBytes]
+		meta["entry."+key+".truncated"] = "true"
+	}
+
+	if len(l.redactFields) == 0 {
+		meta["entry."+key] = string(body)
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		if redacted, ok := l.redactForm(body); ok && !truncated {
+			meta["entry."+key] = redacted
+		}
+	case mediaType == ContentTypeJSON || strings.HasSuffix(mediaType, "+json"):
+		if redacted, ok := l.redactJSON(body); ok && !truncated {
+			meta["entry."+key] = redacted
+		}
+	default:
+		meta["entry."+key] = string(body)
+	}
+}
+
+// loggable returns whether a body with the specified content type is logged.
+func (l *requestLogger) loggable(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if l.bodyTypes[mediaType] {
+		return true
+	}
+
+	if i := strings.Index(mediaType, "/"); i >= 0 {
+		return l.bodyTypes[mediaType[:i+1]]
+	}
+	return false
+}
+
+// redactMeta replaces the values of redacted request and response headers in the meta.
+func (l *requestLogger) redactMeta(meta map[string]string) map[string]string {
+	for _, prefix := range []string{"entry.http.request.header.", "entry.http.header."} {
+		for header := range l.redactHeaders {
+			if _, exists := meta[prefix+header]; exists {
+				meta[prefix+header] = RedactedValue
+			}
+		}
+	}
+	return meta
+}
+
+func (l *requestLogger) redactJSON(body []byte) (string, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return "", false
+	}
+	for _, path := range l.redactFields {
+		redactPath(v, path)
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return "", false
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), true
+}
+
+// redactPath replaces the values at the field path in the decoded JSON value.
+func redactPath(v interface{}, path []string) {
+	switch value := v.(type) {
+	case []interface{}:
+		for _, item := range value {
+			redactPath(item, path)
+		}
+	case map[string]interface{}:
+		for key, field := range value {
+			if path[0] != "*" && !strings.EqualFold(path[0], key) {
+				continue
+			}
+			if len(path) == 1 {
+				value[key] = RedactedValue
+			} else {
+				redactPath(field, path[1:])
+			}
+		}
+	}
+}
+
+func (l *requestLogger) redactForm(body []byte) (string, bool) {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return "", false
+	}
+
+	for key := range values {
+		for _, path := range l.redactFields {
+			if len(path) == 1 && (path[0] == "*" || strings.EqualFold(path[0], key)) {
+				values[key] = []string{RedactedValue}
+			}
+		}
+	}
+	return values.Encode(), true
+}
+
+/* http.ResponseWriter implementation */
+
+func (cw *bodyCaptureWriter) Write(p []byte) (int, error) {
+	if room := cw.max - cw.buf.Len(); room > 0 {
+		if len(p) < room {
+			room = len(p)
+		}
+		cw.buf.Write(p[:room])
+	}
+	return cw.WrappedResponseWriter.Write(p)
+}
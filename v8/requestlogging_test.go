@@ -0,0 +1,209 @@
+package v8_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	sf "github.com/Travix-International/go-servicefoundation/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// logRequest wraps the handle with the middleware and returns the meta of the ApiRequest and ApiResponse loggers.
+func logRequest(mw sf.Middleware, handle sf.Handle, r *http.Request) (map[string]string, map[string]string) {
+	logFactory := &mockLogFactory{}
+	log := &mockLogger{}
+	m := &mockMetrics{}
+	var metas []map[string]string
+	metaFunc := func(_ *http.Request, _ sf.RouterParams) map[string]string {
+		return make(map[string]string)
+	}
+
+	logFactory.On("NewLogger", mock.Anything).Run(func(args mock.Arguments) {
+		meta := make(map[string]string)
+		for key, value := range args.Get(0).(map[string]string) {
+			meta[key] = value
+		}
+		metas = append(metas, meta)
+	}).Return(log)
+	log.On("Info", mock.Anything, mock.Anything, mock.Anything).Return()
+
+	sut := sf.NewMiddlewareWrapper(logFactory, m, &sf.CORSOptions{}, sf.ServiceGlobals{})
+	sut.Wrap("my-sub", "my-name", mw, handle, metaFunc)(sf.NewWrappedResponseWriter(httptest.NewRecorder()), r,
+		sf.RouterParams{})
+
+	// The first logger is created by NewMiddlewareWrapper.
+	return metas[1], metas[2]
+}
+
+func TestMiddlewareWrapperImpl_Wrap_RequestLoggingWithBodies(t *testing.T) {
+	scenarios := []struct {
+		options              sf.RequestLoggingOptions
+		contentType          string
+		body                 string
+		expectedRequestBody  string
+		expectedResponseBody string
+		expectedTruncated    string
+	}{
+		{sf.RequestLoggingOptions{}, sf.ContentTypeJSON, `{"a":1}`, "", "", ""},
+		{sf.RequestLoggingOptions{LogBodies: true}, sf.ContentTypeJSON, `{"a":1}`, `{"a":1}`, `{"echo":{"a":1}}`, ""},
+		{sf.RequestLoggingOptions{LogBodies: true, BodySampleRate: 0.000001}, sf.ContentTypeJSON, `{"a":1}`, "", "",
+			""},
+		{sf.RequestLoggingOptions{LogBodies: true}, "application/octet-stream", `binary`, "", `{"echo":binary}`, ""},
+		{sf.RequestLoggingOptions{LogBodies: true, MaxBodyBytes: 4}, "text/plain", `0123456789`, "0123",
+			`{"ec`, "true"},
+		{sf.RequestLoggingOptions{LogBodies: true, RedactFields: []string{"password", "card.number", "items.*.secret"}},
+			sf.ContentTypeJSON + "; charset=utf-8",
+			`{"user":"joe","Password":"p","card":{"number":"4111","cvc":1},"items":[{"x":{"secret":"s"}},{"x":1}]}`,
+			`{"Password":"[REDACTED]","card":{"cvc":1,"number":"[REDACTED]"},"items":[{"x":{"secret":"[REDACTED]"}},` +
+				`{"x":1}],"user":"joe"}`, "", ""},
+		{sf.RequestLoggingOptions{LogBodies: true, RedactFields: []string{"password"}, MaxBodyBytes: 10},
+			sf.ContentTypeJSON, `{"password":"secret"}`, "", "", "true"},
+		{sf.RequestLoggingOptions{LogBodies: true, RedactFields: []string{"password"}},
+			"application/x-www-form-urlencoded", `user=joe&password=p`, `password=%5BREDACTED%5D&user=joe`, "", ""},
+	}
+
+	for i, scenario := range scenarios {
+		var handlerBody string
+		handle := func(w sf.WrappedResponseWriter, r *http.Request, _ sf.RouterParams) {
+			body, _ := io.ReadAll(r.Body)
+			handlerBody = string(body)
+			w.Header().Set(sf.ContentTypeHeader, sf.ContentTypeJSON)
+			w.Header().Set("Set-Cookie", "session=abc")
+			w.Write([]byte(`{"echo":` + string(body) + `}`))
+		}
+		r := httptest.NewRequest(http.MethodPost, "https://www.sf.com/login", strings.NewReader(scenario.body))
+		r.Header.Set(sf.ContentTypeHeader, scenario.contentType)
+		r.Header.Set("Authorization", "Bearer token")
+		r.Header.Set("X-Custom", "value")
+
+		// Act
+		requestMeta, responseMeta := logRequest(sf.RequestLoggingWith(scenario.options), handle, r)
+
+		assert.Equal(t, scenario.body, handlerBody, "Scenario %d", i)
+		assert.Equal(t, scenario.expectedRequestBody, requestMeta["entry.http.request.body"], "Scenario %d", i)
+		assert.Equal(t, scenario.expectedRequestBody, responseMeta["entry.http.request.body"], "Scenario %d", i)
+		assert.Equal(t, scenario.expectedTruncated, requestMeta["entry.http.request.body.truncated"], "Scenario %d", i)
+		if scenario.expectedResponseBody != "" || !scenario.options.LogBodies {
+			assert.Equal(t, scenario.expectedResponseBody, responseMeta["entry.http.response.body"], "Scenario %d", i)
+		}
+		assert.Equal(t, sf.RedactedValue, responseMeta["entry.http.header.set-cookie"], "Scenario %d", i)
+		if requestMeta["entry.http.request.body"] != "" {
+			assert.Equal(t, sf.RedactedValue, requestMeta["entry.http.request.header.authorization"], "Scenario %d", i)
+			assert.Equal(t, "value", requestMeta["entry.http.request.header.x-custom"], "Scenario %d", i)
+		}
+	}
+}
+
+func TestMiddlewareWrapperImpl_Wrap_RequestLoggingDefault(t *testing.T) {
+	handle := func(w sf.WrappedResponseWriter, r *http.Request, _ sf.RouterParams) {
+		w.Header().Set("X-Custom", "value")
+		w.WriteHeader(http.StatusOK)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/path", nil)
+	r.Header.Set("Authorization", "Bearer token")
+
+	// Act
+	requestMeta, responseMeta := logRequest(sf.RequestLogging, handle, r)
+
+	assert.Equal(t, "GET http://example.com/path", requestMeta["entry.request"])
+	assert.Equal(t, "", requestMeta["entry.http.request.header.authorization"])
+	assert.Equal(t, "200", responseMeta["entry.statuscode"])
+	assert.Equal(t, "value", responseMeta["entry.http.header.x-custom"])
+	assert.NotEmpty(t, responseMeta["entry.duration"])
+}
+
+func TestMiddlewareWrapperImpl_Wrap_RequestLoggingFiltered(t *testing.T) {
+	scenarios := []struct {
+		options          sf.RequestLoggingOptions
+		path             string
+		status           int
+		delay            time.Duration
+		requests         int
+		expectedLogged   int
+		expectedDecision string
+	}{
+		{sf.RequestLoggingOptions{ExcludePaths: []string{"/service/readiness"}}, "/service/readiness", 200, 0, 1, 0,
+			"excluded"},
+		{sf.RequestLoggingOptions{ExcludePaths: []string{"/service/*"}}, "/service/liveness", 200, 0, 1, 0, "excluded"},
+		{sf.RequestLoggingOptions{ExcludePaths: []string{"/service/*"}}, "/orders", 200, 0, 1, 1, "logged"},
+		{sf.RequestLoggingOptions{ExcludePaths: []string{"/service/readiness"}}, "/service/readiness", 503, 0, 1, 1,
+			"logged_error"},
+		{sf.RequestLoggingOptions{SampleRate: 0.000001}, "/orders", 200, 0, 1, 0, "sampled_out"},
+		{sf.RequestLoggingOptions{SampleRate: 0.000001}, "/orders", 404, 0, 1, 1, "logged_error"},
+		{sf.RequestLoggingOptions{SampleRate: 0.000001, SlowThreshold: time.Millisecond}, "/orders", 200,
+			5 * time.Millisecond, 1, 1, "logged_slow"},
+		{sf.RequestLoggingOptions{MaxPerSecond: 2}, "/orders", 200, 0, 3, 2, "rate_limited"},
+	}
+
+	for i, scenario := range scenarios {
+		logFactory := &mockLogFactory{}
+		log := &mockLogger{}
+		m := &mockMetrics{}
+		handle := func(w sf.WrappedResponseWriter, r *http.Request, _ sf.RouterParams) {
+			time.Sleep(scenario.delay)
+			w.WriteHeader(scenario.status)
+		}
+		metaFunc := func(_ *http.Request, _ sf.RouterParams) map[string]string {
+			return make(map[string]string)
+		}
+
+		logFactory.On("NewLogger", mock.Anything).Return(log)
+		log.On("Info", mock.Anything, mock.Anything, mock.Anything).Return()
+		m.On("CountLabels", "", "http_request_logs_total", mock.Anything, []string{"handler", "decision"},
+			mock.Anything).Return()
+
+		sut := sf.NewMiddlewareWrapper(logFactory, m, &sf.CORSOptions{}, sf.ServiceGlobals{})
+		wrapped := sut.Wrap("my-sub", "my-name", sf.RequestLoggingWith(scenario.options), handle, metaFunc)
+
+		// Act
+		for j := 0; j < scenario.requests; j++ {
+			wrapped(sf.NewWrappedResponseWriter(httptest.NewRecorder()), httptest.NewRequest(http.MethodGet,
+				scenario.path, nil), sf.RouterParams{})
+		}
+
+		log.AssertNumberOfCalls(t, "Info", scenario.expectedLogged*2)
+		m.AssertCalled(t, "CountLabels", "", "http_request_logs_total", mock.Anything, []string{"handler", "decision"},
+			[]string{"my-name", scenario.expectedDecision})
+		assert.Equal(t, scenario.requests, len(m.Calls), "Scenario %d", i)
+	}
+}
+
+func TestMiddlewareWrapperImpl_Wrap_RequestLoggingFilteredOrder(t *testing.T) {
+	handle := func(w sf.WrappedResponseWriter, r *http.Request, _ sf.RouterParams) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/path", nil)
+	metaFunc := func(_ *http.Request, _ sf.RouterParams) map[string]string {
+		return make(map[string]string)
+	}
+	mw := sf.RequestLoggingWith(sf.RequestLoggingOptions{SampleRate: 0.000001})
+
+	logFactory := &mockLogFactory{}
+	log := &mockLogger{}
+	m := &mockMetrics{}
+	var events []string
+	var metas []map[string]string
+
+	logFactory.On("NewLogger", mock.Anything).Run(func(args mock.Arguments) {
+		metas = append(metas, args.Get(0).(map[string]string))
+	}).Return(log)
+	log.On("Info", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		events = append(events, args.String(0))
+	}).Return()
+	m.On("CountLabels", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+
+	sut := sf.NewMiddlewareWrapper(logFactory, m, &sf.CORSOptions{}, sf.ServiceGlobals{})
+
+	// Act
+	sut.Wrap("my-sub", "my-name", mw, handle, metaFunc)(sf.NewWrappedResponseWriter(httptest.NewRecorder()), r,
+		sf.RouterParams{})
+
+	assert.Equal(t, []string{"ApiRequest", "ApiResponse"}, events)
+	assert.Equal(t, "", metas[1]["entry.statuscode"])
+	assert.Equal(t, "500", metas[2]["entry.statuscode"])
+}
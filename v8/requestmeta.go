@@ -0,0 +1,74 @@
+package v8
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+type (
+	requestMetaKey struct{}
+
+	requestMeta struct {
+		mutex  sync.Mutex
+		fields map[string]string
+	}
+)
+
+// AddRequestMeta adds a field to the meta of all log messages written by the middleware for the current request,
+// including middleware that wraps the caller. It has no effect for requests of routes without the RequestLogging,
+// RequestID, Auth or Authentication middleware, since only those routes keep a request meta.
+func AddRequestMeta(r *http.Request, key, value string) {
+	rm, ok := r.Context().Value(requestMetaKey{}).(*requestMeta)
+	if !ok {
+		return
+	}
+
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+
+	if rm.fields == nil {
+		rm.fields = make(map[string]string)
+	}
+	rm.fields[key] = value
+}
+
+// withRequestMeta returns a shallow copy of the request with an empty request meta in its context.
+func withRequestMeta(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), requestMetaKey{}, &requestMeta{}))
+}
+
+// registerRequestMetaMiddleware registers the specified wrap function of a middleware that adds fields to or logs the
+// request meta, and returns its Middleware enumeration.
+func registerRequestMetaMiddleware(wrap func(m *middlewareWrapperImpl, subsystem, name string, handler Handle,
+	metaFunc MetaFunc) Handle) Middleware {
+
+	return addRegistration(&middlewareRegistration{wrap: wrap, requestMeta: true})
+}
+
+// usesRequestMeta returns whether the middleware adds fields to or logs the request meta, so requests of routes
+// without them can skip allocating one.
+func usesRequestMeta(middleware Middleware) bool {
+	switch middleware {
+	case RequestLogging, RequestID:
+		return true
+	}
+
+	registration, exists := getRegisteredMiddleware(middleware)
+	return exists && registration.requestMeta
+}
+
+// copyRequestMeta copies the fields added with AddRequestMeta to the specified meta.
+func copyRequestMeta(r *http.Request, meta map[string]string) {
+	rm, ok := r.Context().Value(requestMetaKey{}).(*requestMeta)
+	if !ok {
+		return
+	}
+
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+
+	for key, value := range rm.fields {
+		meta[key] = value
+	}
+}
@@ -74,6 +74,17 @@ func (w *wrappedResponseWriterImpl) WriteHeader(code int) {
 	w.wroteHeader = true
 }
 
+func (w *wrappedResponseWriterImpl) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the original http.ResponseWriter, for use by http.ResponseController.
+func (w *wrappedResponseWriterImpl) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 func (w *wrappedResponseWriterImpl) JSON(statusCode int, content interface{}) {
 	w.Header().Set(ContentTypeHeader, ContentTypeJSON)
 	w.WriteHeader(statusCode)
@@ -103,6 +114,34 @@ func (w *wrappedResponseWriterImpl) WriteResponse(r *http.Request, statusCode in
 }
 
 func (w *wrappedResponseWriterImpl) SetCaching(maxAge int) {
-	w.Header().Set("Vary", "Accept, Origin") // Because we don't want to mix XML and JSON in the cache!
+	addVaryHeader(w.Header(), "Accept", "Origin") // Because we don't want to mix XML and JSON in the cache!
 	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%v", maxAge))
 }
+
+func writeErrorResponse(w WrappedResponseWriter, r *http.Request, statusCode int, message string) {
+	w.WriteResponse(r, statusCode, ErrorResponse{Message: message})
+}
+
+// addVaryHeader adds the specified header names to the Vary header, skipping names that are already present.
+func addVaryHeader(h http.Header, names ...string) {
+	existing := make(map[string]bool)
+
+	for _, value := range h.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			existing[strings.ToLower(strings.TrimSpace(name))] = true
+		}
+	}
+
+	var missing []string
+	for _, name := range names {
+		if existing[strings.ToLower(name)] {
+			continue
+		}
+		existing[strings.ToLower(name)] = true
+		missing = append(missing, name)
+	}
+
+	if len(missing) > 0 {
+		h.Add("Vary", strings.Join(missing, ", "))
+	}
+}
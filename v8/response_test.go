@@ -2,6 +2,7 @@ package v8_test
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	sf "github.com/Travix-International/go-servicefoundation/v8"
@@ -182,3 +183,13 @@ func TestWrappedResponseWriterImpl_SetCaching(t *testing.T) {
 	assert.Equal(t, "public, max-age=66", h.Get("Cache-Control"))
 	w.AssertExpectations(t)
 }
+
+func TestWrappedResponseWriterImpl_SetCaching_MergesVary(t *testing.T) {
+	w := httptest.NewRecorder()
+	w.Header().Set("Vary", "Accept-Encoding, accept")
+	sut := sf.NewWrappedResponseWriter(w)
+
+	sut.SetCaching(66)
+
+	assert.Equal(t, []string{"Accept-Encoding, accept", "Origin"}, w.Header().Values("Vary"))
+}
@@ -0,0 +1,519 @@
+package v8
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultResponseCacheName       = "default"
+	defaultResponseCacheMaxEntries = 1000
+
+	// CacheStatusHeader is the name of the http header indicating whether a response was served from the cache.
+	CacheStatusHeader = "X-Cache"
+)
+
+type (
+	// ResponseCacheOptions contains properties used by NewResponseCache.
+	ResponseCacheOptions struct {
+		// Name identifies the cache in metrics and in the purge endpoint. Default value is "default".
+		Name string
+		// MaxEntries is the maximum number of responses in the cache. Default value is 1000.
+		MaxEntries int
+		// QueryParams contains the query parameters that are part of the cache key. When nil, all query parameters
+		// are part of the key.
+		QueryParams []string
+		// StaleWhileRevalidate is the time after expiry a response is still served while it is refreshed in the
+		// background.
+		StaleWhileRevalidate time.Duration
+	}
+
+	// ResponseCache is an interface for an in-process cache of GET responses, used by the ResponseCaching middleware.
+	// Keys are the path of a request followed by the selected query parameters in alphabetical order, like
+	// "/products?id=1". Caches are created with NewResponseCache; the interface can not be implemented outside this
+	// package.
+	ResponseCache interface {
+		// Name returns the name of the cache.
+		Name() string
+		// Purge removes the responses with the specified key and returns the number of removed responses.
+		Purge(key string) int
+		// PurgePrefix removes the responses with keys starting with the specified prefix and returns the number of
+		// removed responses.
+		PurgePrefix(prefix string) int
+		// Len returns the number of responses in the cache.
+		Len() int
+
+		// impl returns the implementation used by the ResponseCaching middleware.
+		impl() *responseCacheImpl
+	}
+
+	responseCacheImpl struct {
+		mutex       sync.Mutex
+		options     ResponseCacheOptions
+		queryParams map[string]bool
+		entries     map[string]*list.Element
+		lru         *list.List
+		vary        map[string]*cacheVary
+		calls       map[string]*cacheCall
+	}
+
+	// cacheVary contains the header names the responses for a path key vary on, and the number of cached responses
+	// for the path key, so it is removed with the last one.
+	cacheVary struct {
+		names   []string
+		entries int
+	}
+
+	cacheEntry struct {
+		key          string
+		base         string
+		path         string
+		status       int
+		header       http.Header
+		body         []byte
+		stored       time.Time
+		maxAge       time.Duration
+		revalidating bool
+	}
+
+	// cacheCall coalesces concurrent misses for the same key.
+	cacheCall struct {
+		done  chan struct{}
+		key   string
+		entry *cacheEntry
+	}
+
+	purgeResponse struct {
+		Purged int
+	}
+)
+
+// NewResponseCache instantiates a new ResponseCache implementation, which evicts the least recently used responses.
+func NewResponseCache(options ResponseCacheOptions) ResponseCache {
+	if options.Name == "" {
+		options.Name = defaultResponseCacheName
+	}
+	if options.MaxEntries <= 0 {
+		options.MaxEntries = defaultResponseCacheMaxEntries
+	}
+
+	var queryParams map[string]bool
+	if options.QueryParams != nil {
+		queryParams = make(map[string]bool)
+		for _, name := range options.QueryParams {
+			queryParams[name] = true
+		}
+	}
+
+	return &responseCacheImpl{
+		options:     options,
+		queryParams: queryParams,
+		entries:     make(map[string]*list.Element),
+		lru:         list.New(),
+		vary:        make(map[string]*cacheVary),
+		calls:       make(map[string]*cacheCall),
+	}
+}
+
+// ResponseCaching returns a Middleware enumeration that serves GET requests from the specified cache. Responses are
+// stored for the max-age set with WrappedResponseWriter.SetCaching; responses without max-age, or with private,
+// no-cache or no-store, are not stored. Using the returned Middleware for multiple routes shares the cache.
+func ResponseCaching(cache ResponseCache) Middleware {
+	return registerMiddleware("", func(m *middlewareWrapperImpl, subsystem, name string, handler Handle,
+		_ MetaFunc) Handle {
+
+		return m.wrapWithResponseCache(name, handler, cache.impl())
+	})
+}
+
+// NewResponseCachePurgeHandler creates a Handle that purges responses from the specified caches. It takes the key or
+// prefix to purge from the "key" or "prefix" query parameter, and optionally the name of a cache from the "cache"
+// query parameter.
+func NewResponseCachePurgeHandler(caches []ResponseCache) Handle {
+	return func(w WrappedResponseWriter, r *http.Request, _ RouterParams) {
+		query := r.URL.Query()
+		key, prefix, name := query.Get("key"), query.Get("prefix"), query.Get("cache")
+
+		if key == "" && prefix == "" {
+			writeErrorResponse(w, r, http.StatusBadRequest, "Either query parameter 'key' or 'prefix' is required")
+			return
+		}
+
+		purged := 0
+		for _, cache := range caches {
+			if name != "" && cache.Name() != name {
+				continue
+			}
+			if key != "" {
+				purged += cache.Purge(key)
+			} else {
+				purged += cache.PurgePrefix(prefix)
+			}
+		}
+		w.WriteResponse(r, http.StatusOK, purgeResponse{Purged: purged})
+	}
+}
+
+/* ResponseCache implementation */
+
+func (c *responseCacheImpl) Name() string {
+	return c.options.Name
+}
+
+func (c *responseCacheImpl) Purge(key string) int {
+	if u, err := url.Parse(key); err == nil {
+		key = c.pathKey(u)
+	}
+	return c.purge(func(path string) bool { return path == key })
+}
+
+func (c *responseCacheImpl) PurgePrefix(prefix string) int {
+	return c.purge(func(path string) bool { return strings.HasPrefix(path, prefix) })
+}
+
+func (c *responseCacheImpl) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.lru.Len()
+}
+
+func (c *responseCacheImpl) impl() *responseCacheImpl {
+	return c
+}
+
+func (c *responseCacheImpl) purge(match func(path string) bool) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	purged := 0
+	for element := c.lru.Front(); element != nil; {
+		next := element.Next()
+		if entry := element.Value.(*cacheEntry); match(entry.path) {
+			c.remove(element)
+			purged++
+		}
+		element = next
+	}
+	return purged
+}
+
+// pathKey returns the path with the selected query parameters in alphabetical order.
+func (c *responseCacheImpl) pathKey(u *url.URL) string {
+	query := u.Query()
+	for name := range query {
+		if c.queryParams != nil && !c.queryParams[name] {
+			delete(query, name)
+		}
+	}
+
+	if encoded := query.Encode(); encoded != "" {
+		return u.Path + "?" + encoded
+	}
+	return u.Path
+}
+
+// varyKey returns the cache key of the request, including the values of the headers the response varies on.
+func varyKey(base string, names []string, r *http.Request) string {
+	var sb strings.Builder
+	sb.WriteString(base)
+
+	for _, name := range names {
+		sb.WriteString("\n")
+		sb.WriteString(name)
+		sb.WriteString(":")
+		sb.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+	return sb.String()
+}
+
+func (c *responseCacheImpl) varyNames(base string) []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if vary, exists := c.vary[base]; exists {
+		return vary.names
+	}
+	return nil
+}
+
+// lookup returns the entry for the key, whether it is stale and whether the caller should revalidate it.
+func (c *responseCacheImpl) lookup(key string, now time.Time) (*cacheEntry, bool, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, exists := c.entries[key]
+	if !exists {
+		return nil, false, false
+	}
+
+	entry := element.Value.(*cacheEntry)
+	age := now.Sub(entry.stored)
+
+	if age < entry.maxAge {
+		c.lru.MoveToFront(element)
+		return entry, false, false
+	}
+	if age < entry.maxAge+c.options.StaleWhileRevalidate {
+		c.lru.MoveToFront(element)
+		revalidate := !entry.revalidating
+		entry.revalidating = true
+		return entry, true, revalidate
+	}
+
+	c.remove(element)
+	return nil, false, false
+}
+
+// join returns the call for the key and whether the caller leads it.
+func (c *responseCacheImpl) join(key string) (*cacheCall, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if call, exists := c.calls[key]; exists {
+		return call, false
+	}
+
+	call := &cacheCall{done: make(chan struct{}), key: key}
+	c.calls[key] = call
+	return call, true
+}
+
+func (c *responseCacheImpl) finish(call *cacheCall, entry *cacheEntry) {
+	c.mutex.Lock()
+	delete(c.calls, call.key)
+	c.mutex.Unlock()
+
+	call.entry = entry
+	close(call.done)
+}
+
+// store adds the entry to the cache when it is cacheable, and returns the number of evicted entries.
+func (c *responseCacheImpl) store(base string, varyNames []string, entry *cacheEntry) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, exists := c.entries[entry.key]; exists {
+		c.remove(element)
+	}
+
+	vary, exists := c.vary[base]
+	if !exists {
+		vary = &cacheVary{}
+		c.vary[base] = vary
+	}
+	vary.names = varyNames
+	vary.entries++
+
+	entry.base = base
+	c.entries[entry.key] = c.lru.PushFront(entry)
+
+	evicted := 0
+	for c.lru.Len() > c.options.MaxEntries {
+		c.remove(c.lru.Back())
+		evicted++
+	}
+	return evicted
+}
+
+func (c *responseCacheImpl) endRevalidation(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, exists := c.entries[key]; exists {
+		element.Value.(*cacheEntry).revalidating = false
+	}
+}
+
+func (c *responseCacheImpl) remove(element *list.Element) {
+	entry := element.Value.(*cacheEntry)
+	c.lru.Remove(element)
+	delete(c.entries, entry.key)
+
+	if vary, exists := c.vary[entry.base]; exists {
+		if vary.entries--; vary.entries <= 0 {
+			delete(c.vary, entry.base)
+		}
+	}
+}
+
+func (m *middlewareWrapperImpl) wrapWithResponseCache(name string, handler Handle, c *responseCacheImpl) Handle {
+	cacheName := c.options.Name
+	handlerName := strings.ToLower(name)
+	entriesName := fmt.Sprintf("response_cache_%v_entries", strings.ToLower(cacheName))
+
+	count := func(result string) {
+		m.metrics.CountLabels("", "http_response_cache_requests_total", "Total requests handled by response caches.",
+			[]string{"cache", "handler", "result"}, []string{cacheName, handlerName, result})
+	}
+
+	// render runs the handler and returns its response as cache entry, with the max-age when it can be cached.
+	render := func(r *http.Request, p RouterParams, base string) (*cacheEntry, []string, bool) {
+		rb := newResponseBuffer(make(http.Header))
+		handler(NewWrappedResponseWriter(rb), r, p)
+
+		entry := &cacheEntry{
+			path:   strings.TrimPrefix(base, http.MethodGet+" "),
+			status: rb.status,
+			header: rb.header,
+			body:   rb.body.Bytes(),
+			stored: time.Now(),
+		}
+		varyNames, varyOK := parseVary(rb.header)
+		entry.key = varyKey(base, varyNames, r)
+
+		maxAge, ok := cacheMaxAge(r, rb.status, rb.header)
+		entry.maxAge = maxAge
+		return entry, varyNames, ok && varyOK
+	}
+
+	store := func(base string, varyNames []string, entry *cacheEntry) {
+		for evicted := c.store(base, varyNames, entry); evicted > 0; evicted-- {
+			m.metrics.CountLabels("", "http_response_cache_evictions_total", "Total responses evicted from caches.",
+				[]string{"cache"}, []string{cacheName})
+		}
+		m.metrics.SetGauge(float64(c.Len()), "", entriesName, "Number of responses in the cache.")
+	}
+
+	revalidate := func(r *http.Request, p RouterParams, base, key string) {
+		defer c.endRevalidation(key)
+		defer func() {
+			if err := recover(); err != nil {
+				m.log.Error("ResponseCacheRevalidation", "Revalidating %s panicked: %v", key, err)
+			}
+		}()
+
+		entry, varyNames, ok := render(r.Clone(context.WithoutCancel(r.Context())), p, base)
+		if ok {
+			store(base, varyNames, entry)
+		}
+	}
+
+	return func(w WrappedResponseWriter, r *http.Request, p RouterParams) {
+		if r.Method != http.MethodGet {
+			handler(w, r, p)
+			return
+		}
+
+		base := http.MethodGet + " " + c.pathKey(r.URL)
+		key := varyKey(base, c.varyNames(base), r)
+		now := time.Now()
+
+		if entry, stale, shouldRevalidate := c.lookup(key, now); entry != nil {
+			if stale {
+				count("stale")
+				writeCacheEntry(w, entry, "STALE", now)
+				if shouldRevalidate {
+					go revalidate(r, p, base, key)
+				}
+				return
+			}
+			count("hit")
+			writeCacheEntry(w, entry, "HIT", now)
+			return
+		}
+
+		count("miss")
+		call, leader := c.join(key)
+		// Only cacheable responses are shared with waiting requests; otherwise they render their own.
+		var shared *cacheEntry
+
+		if leader {
+			// Waiting requests are released even when the handler panics.
+			defer func() { c.finish(call, shared) }()
+		} else {
+			select {
+			case <-call.done:
+			case <-r.Context().Done():
+				return
+			}
+			// The leader may have learned that the response varies on headers that differ for this request.
+			if call.entry != nil && varyKey(base, c.varyNames(base), r) == call.entry.key {
+				writeCacheEntry(w, call.entry, "MISS", now)
+				return
+			}
+		}
+
+		entry, varyNames, ok := render(r, p, base)
+		if ok {
+			store(base, varyNames, entry)
+			shared = entry
+		}
+		writeCacheEntry(w, entry, "MISS", entry.stored)
+	}
+}
+
+// writeCacheEntry writes the response of the entry with its age and the cache status.
+func writeCacheEntry(w WrappedResponseWriter, entry *cacheEntry, cacheStatus string, now time.Time) {
+	h := w.Header()
+	for key, values := range entry.header {
+		h[key] = append([]string(nil), values...)
+	}
+	if age := now.Sub(entry.stored); age >= time.Second {
+		h.Set("Age", strconv.Itoa(int(age.Seconds())))
+	}
+	h.Set(CacheStatusHeader, cacheStatus)
+
+	w.WriteHeader(entry.status)
+	w.Write(entry.body)
+}
+
+// parseVary returns the sorted header names of the Vary header, or false when the response varies on everything.
+func parseVary(h http.Header) ([]string, bool) {
+	var names []string
+
+	for _, value := range h.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "*" {
+				return nil, false
+			}
+			if name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, true
+}
+
+// cacheMaxAge returns the max-age of a response and whether it can be stored in a shared cache.
+func cacheMaxAge(r *http.Request, status int, h http.Header) (time.Duration, bool) {
+	if status != http.StatusOK || h.Get("Set-Cookie") != "" {
+		return 0, false
+	}
+
+	maxAge := -1
+	public := false
+
+	for _, directive := range strings.Split(h.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+
+		switch strings.ToLower(name) {
+		case "no-store", "no-cache", "private":
+			return 0, false
+		case "public":
+			public = true
+		case "max-age":
+			if seconds, err := strconv.Atoi(value); err == nil && maxAge < 0 {
+				maxAge = seconds
+			}
+		case "s-maxage":
+			if seconds, err := strconv.Atoi(value); err == nil {
+				maxAge = seconds
+			}
+		}
+	}
+
+	if maxAge <= 0 || (r.Header.Get("Authorization") != "" && !public) {
+		return 0, false
+	}
+	return time.Duration(maxAge) * time.Second, true
+}
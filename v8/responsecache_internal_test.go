@@ -0,0 +1,24 @@
+package v8
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseCacheImpl_RemovesVaryNamesWithLastEntry(t *testing.T) {
+	sut := NewResponseCache(ResponseCacheOptions{MaxEntries: 2}).impl()
+	names := []string{"Accept-Language"}
+
+	// Act
+	sut.store("GET /a", names, &cacheEntry{key: "GET /a\nAccept-Language:en", path: "/a"})
+	sut.store("GET /a", names, &cacheEntry{key: "GET /a\nAccept-Language:nl", path: "/a"})
+	sut.store("GET /b", nil, &cacheEntry{key: "GET /b", path: "/b"})
+	afterEviction := sut.varyNames("GET /a")
+	sut.store("GET /c", nil, &cacheEntry{key: "GET /c", path: "/c"})
+	purged := sut.PurgePrefix("/")
+
+	assert.Equal(t, names, afterEviction)
+	assert.Equal(t, 2, purged)
+	assert.Empty(t, sut.vary)
+}
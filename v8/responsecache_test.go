@@ -0,0 +1,290 @@
+package v8_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sf "github.com/Travix-International/go-servicefoundation/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newResponseCacheWrapper(m *mockMetrics) sf.MiddlewareWrapper {
+	logFactory := &mockLogFactory{}
+	log := &mockLogger{}
+
+	logFactory.On("NewLogger", mock.Anything).Return(log)
+	log.On("Error", mock.Anything, mock.Anything, mock.Anything).Maybe()
+	m.On("CountLabels", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+	m.On("SetGauge", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+
+	return sf.NewMiddlewareWrapper(logFactory, m, &sf.CORSOptions{}, sf.ServiceGlobals{})
+}
+
+func TestMiddlewareWrapperImpl_Wrap_ResponseCaching(t *testing.T) {
+	scenarios := []struct {
+		cacheControl  string
+		authorization string
+		method        string
+		secondURL     string
+		secondAccept  string
+		expectedCache string
+		expectedCalls int32
+	}{
+		{"public, max-age=60", "", http.MethodGet, "/products?id=1", "", "HIT", 1},
+		{"public, max-age=60", "", http.MethodGet, "/products?id=1&utm=x", "", "HIT", 1},
+		{"public, max-age=60", "", http.MethodGet, "/products?id=2", "", "MISS", 2},
+		{"public, max-age=60", "", http.MethodGet, "/products?id=1", sf.ContentTypeXML, "MISS", 2},
+		{"private, max-age=60", "", http.MethodGet, "/products?id=1", "", "MISS", 2},
+		{"no-store", "", http.MethodGet, "/products?id=1", "", "MISS", 2},
+		{"", "", http.MethodGet, "/products?id=1", "", "MISS", 2},
+		{"max-age=60", "Bearer token", http.MethodGet, "/products?id=1", "", "MISS", 2},
+		{"public, max-age=60", "Bearer token", http.MethodGet, "/products?id=1", "", "HIT", 1},
+		{"public, max-age=60", "", http.MethodPost, "/products?id=1", "", "", 2},
+	}
+
+	for i, scenario := range scenarios {
+		m := &mockMetrics{}
+		sut := newResponseCacheWrapper(m)
+		cache := sf.NewResponseCache(sf.ResponseCacheOptions{QueryParams: []string{"id"}})
+		var calls int32
+
+		handle := func(w sf.WrappedResponseWriter, r *http.Request, _ sf.RouterParams) {
+			n := atomic.AddInt32(&calls, 1)
+			w.Header().Set("Vary", "Accept")
+			if scenario.cacheControl != "" {
+				w.Header().Set("Cache-Control", scenario.cacheControl)
+			}
+			w.WriteResponse(r, http.StatusOK, map[string]int32{"call": n})
+		}
+		wrapped := sut.Wrap("my-sub", "my-name", sf.ResponseCaching(cache), handle, nil)
+
+		serve := func(url, accept string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(scenario.method, "https://www.sf.com"+url, nil)
+			r.Header.Set("Authorization", scenario.authorization)
+			r.Header.Set("Accept", accept)
+			wrapped(sf.NewWrappedResponseWriter(w), r, sf.RouterParams{})
+			return w
+		}
+
+		// Act
+		first := serve("/products?utm=y&id=1", "")
+		second := serve(scenario.secondURL, scenario.secondAccept)
+
+		assert.Equal(t, http.StatusOK, second.Code, "Scenario %d", i)
+		assert.Equal(t, scenario.expectedCache, second.Header().Get(sf.CacheStatusHeader), "Scenario %d", i)
+		assert.Equal(t, scenario.expectedCalls, atomic.LoadInt32(&calls), "Scenario %d", i)
+		if scenario.expectedCache == "HIT" {
+			assert.Equal(t, first.Body.String(), second.Body.String(), "Scenario %d", i)
+			assert.Equal(t, scenario.cacheControl, second.Header().Get("Cache-Control"), "Scenario %d", i)
+		}
+	}
+}
+
+func TestMiddlewareWrapperImpl_Wrap_ResponseCachingEviction(t *testing.T) {
+	m := &mockMetrics{}
+	sut := newResponseCacheWrapper(m)
+	cache := sf.NewResponseCache(sf.ResponseCacheOptions{Name: "products", MaxEntries: 2})
+	handle := func(w sf.WrappedResponseWriter, r *http.Request, _ sf.RouterParams) {
+		w.SetCaching(60)
+		w.WriteResponse(r, http.StatusOK, r.URL.Path)
+	}
+	wrapped := sut.Wrap("my-sub", "my-name", sf.ResponseCaching(cache), handle, nil)
+
+	serve := func(path string) string {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "https://www.sf.com"+path, nil)
+		wrapped(sf.NewWrappedResponseWriter(w), r, sf.RouterParams{})
+		return w.Header().Get(sf.CacheStatusHeader)
+	}
+
+	// Act
+	serve("/a")
+	serve("/b")
+	serve("/a")
+	serve("/c")
+
+	assert.Equal(t, 2, cache.Len())
+	assert.Equal(t, "HIT", serve("/a"))
+	assert.Equal(t, "MISS", serve("/b"))
+	m.AssertCalled(t, "CountLabels", "", "http_response_cache_evictions_total", mock.Anything,
+		[]string{"cache"}, []string{"products"})
+	m.AssertCalled(t, "CountLabels", "", "http_response_cache_requests_total", mock.Anything,
+		[]string{"cache", "handler", "result"}, []string{"products", "my-name", "hit"})
+	m.AssertCalled(t, "SetGauge", float64(2), "", "response_cache_products_entries", mock.Anything)
+}
+
+func TestMiddlewareWrapperImpl_Wrap_ResponseCachingCoalescing(t *testing.T) {
+	m := &mockMetrics{}
+	sut := newResponseCacheWrapper(m)
+	cache := sf.NewResponseCache(sf.ResponseCacheOptions{})
+	release := make(chan struct{})
+	var calls int32
+
+	handle := func(w sf.WrappedResponseWriter, r *http.Request, _ sf.RouterParams) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		w.SetCaching(60)
+		w.WriteResponse(r, http.StatusOK, "slow")
+	}
+	wrapped := sut.Wrap("my-sub", "my-name", sf.ResponseCaching(cache), handle, nil)
+
+	var wg sync.WaitGroup
+	recorders := make([]*httptest.ResponseRecorder, 5)
+	for i := range recorders {
+		recorders[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(w *httptest.ResponseRecorder) {
+			defer wg.Done()
+			r := httptest.NewRequest(http.MethodGet, "https://www.sf.com/slow", nil)
+			wrapped(sf.NewWrappedResponseWriter(w), r, sf.RouterParams{})
+		}(recorders[i])
+	}
+
+	// Act
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	for i, w := range recorders {
+		assert.Equal(t, http.StatusOK, w.Code, "Request %d", i)
+		assert.Equal(t, "\"slow\"\n", w.Body.String(), "Request %d", i)
+	}
+}
+
+func TestMiddlewareWrapperImpl_Wrap_ResponseCachingLeaderPanics(t *testing.T) {
+	m := &mockMetrics{}
+	sut := newResponseCacheWrapper(m)
+	cache := sf.NewResponseCache(sf.ResponseCacheOptions{})
+	release := make(chan struct{})
+	var calls int32
+
+	handle := func(w sf.WrappedResponseWriter, r *http.Request, _ sf.RouterParams) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-release
+			panic("boom")
+		}
+		w.SetCaching(60)
+		w.WriteResponse(r, http.StatusOK, "ok")
+	}
+	metaFunc := func(*http.Request, sf.RouterParams) map[string]string {
+		return make(map[string]string)
+	}
+	cached := sut.Wrap("my-sub", "my-name", sf.ResponseCaching(cache), handle, metaFunc)
+	wrapped := sut.Wrap("my-sub", "my-name", sf.PanicTo500, cached, metaFunc)
+
+	serve := func(w *httptest.ResponseRecorder) {
+		r := httptest.NewRequest(http.MethodGet, "https://www.sf.com/panics", nil)
+		wrapped(sf.NewWrappedResponseWriter(w), r, sf.RouterParams{})
+	}
+	leader := httptest.NewRecorder()
+	follower := httptest.NewRecorder()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		serve(leader)
+	}()
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&calls) == 1 }, time.Second, time.Millisecond)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		serve(follower)
+	}()
+
+	// Act
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, http.StatusInternalServerError, leader.Code)
+	assert.Equal(t, http.StatusOK, follower.Code)
+	assert.Equal(t, "\"ok\"\n", follower.Body.String())
+
+	w := httptest.NewRecorder()
+	serve(w)
+	assert.Equal(t, "HIT", w.Header().Get(sf.CacheStatusHeader))
+}
+
+func TestMiddlewareWrapperImpl_Wrap_ResponseCachingStaleWhileRevalidate(t *testing.T) {
+	m := &mockMetrics{}
+	sut := newResponseCacheWrapper(m)
+	cache := sf.NewResponseCache(sf.ResponseCacheOptions{StaleWhileRevalidate: time.Minute})
+	var calls int32
+
+	handle := func(w sf.WrappedResponseWriter, r *http.Request, _ sf.RouterParams) {
+		n := atomic.AddInt32(&calls, 1)
+		w.SetCaching(1)
+		w.WriteResponse(r, http.StatusOK, n)
+	}
+	wrapped := sut.Wrap("my-sub", "my-name", sf.ResponseCaching(cache), handle, nil)
+
+	serve := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "https://www.sf.com/data", nil)
+		wrapped(sf.NewWrappedResponseWriter(w), r, sf.RouterParams{})
+		return w
+	}
+	serve()
+	time.Sleep(1100 * time.Millisecond)
+
+	// Act
+	stale := serve()
+
+	assert.Equal(t, "STALE", stale.Header().Get(sf.CacheStatusHeader))
+	assert.Equal(t, "1\n", stale.Body.String())
+	assert.Equal(t, "1", stale.Header().Get("Age"))
+	assert.Eventually(t, func() bool { return serve().Body.String() == "2\n" }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestResponseCache_Purge(t *testing.T) {
+	m := &mockMetrics{}
+	sut := newResponseCacheWrapper(m)
+	cache := sf.NewResponseCache(sf.ResponseCacheOptions{Name: "products"})
+	other := sf.NewResponseCache(sf.ResponseCacheOptions{Name: "other"})
+	handle := func(w sf.WrappedResponseWriter, r *http.Request, _ sf.RouterParams) {
+		w.SetCaching(60)
+		w.WriteResponse(r, http.StatusOK, r.URL.Path)
+	}
+	wrapped := sut.Wrap("my-sub", "my-name", sf.ResponseCaching(cache), handle, nil)
+
+	for _, path := range []string{"/products/1?b=2&a=1", "/products/2", "/products/3", "/orders/1"} {
+		r := httptest.NewRequest(http.MethodGet, "https://www.sf.com"+path, nil)
+		wrapped(sf.NewWrappedResponseWriter(httptest.NewRecorder()), r, sf.RouterParams{})
+	}
+	purge := sf.NewResponseCachePurgeHandler([]sf.ResponseCache{cache, other})
+
+	scenarios := []struct {
+		query          string
+		expectedStatus int
+		expectedBody   string
+		expectedLen    int
+	}{
+		{"", http.StatusBadRequest, `{"Message":"Either query parameter 'key' or 'prefix' is required"}` + "\n", 4},
+		{"key=/products/1?b=2%26a=1", http.StatusOK, `{"Purged":1}` + "\n", 3},
+		{"prefix=/products/&cache=other", http.StatusOK, `{"Purged":0}` + "\n", 3},
+		{"prefix=/products/&cache=products", http.StatusOK, `{"Purged":2}` + "\n", 1},
+		{"key=/products/2", http.StatusOK, `{"Purged":0}` + "\n", 1},
+	}
+
+	for i, scenario := range scenarios {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("https://www.sf.com/cache?%s", scenario.query), nil)
+		r.Header.Set("Accept", sf.ContentTypeJSON)
+
+		// Act
+		purge(sf.NewWrappedResponseWriter(w), r, sf.RouterParams{})
+
+		assert.Equal(t, scenario.expectedStatus, w.Code, "Scenario %d", i)
+		assert.Equal(t, scenario.expectedBody, w.Body.String(), "Scenario %d", i)
+		assert.Equal(t, scenario.expectedLen, cache.Len(), "Scenario %d", i)
+	}
+}
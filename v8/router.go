@@ -2,6 +2,7 @@ package v8
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/julienschmidt/httprouter"
 )
@@ -13,23 +14,43 @@ type (
 	// MetaFunc is a function that returns a map containing meta data used to enrich log messages.
 	MetaFunc func(*http.Request, RouterParams) map[string]string
 
-	// RouterParams is a struct that wraps httprouter.Params
+	// RouterParams is a struct that wraps the path parameters. Every Router implementation provides them as
+	// httprouter.Params, so existing callers keep working regardless of the implementation.
 	RouterParams struct {
 		Params httprouter.Params
 	}
 
-	// Router is a struct that wraps httprouter.Router
-	Router struct {
-		Router *httprouter.Router
+	// RouterHandle is a function signature for the handles registered with a Router.
+	RouterHandle func(http.ResponseWriter, *http.Request, RouterParams)
+
+	// Router is an interface to register handles for a method and path. Paths use the httprouter syntax for named
+	// parameters (/users/:id) and catch-all parameters (/files/*filepath), regardless of the implementation.
+	Router interface {
+		http.Handler
+		Handle(method, path string, handle RouterHandle)
+		NotFound(handler http.Handler)
 	}
 
 	// RouterFactory is an interface to create a new Router.
 	RouterFactory interface {
-		NewRouter() *Router
+		NewRouter() Router
 	}
 
 	routerFactoryImpl struct {
 	}
+
+	serveMuxRouterFactoryImpl struct {
+	}
+
+	httpRouterImpl struct {
+		router *httprouter.Router
+	}
+
+	serveMuxRouterImpl struct {
+		mux      *http.ServeMux
+		notFound http.Handler
+		methods  map[string]bool
+	}
 )
 
 var (
@@ -39,13 +60,126 @@ var (
 	MethodsForPost = []string{http.MethodPost}
 )
 
-// NewRouterFactory instantiates a new RouterFactory implementation.
+// NewRouterFactory instantiates a new RouterFactory implementation, which creates routers based on httprouter.
 func NewRouterFactory() RouterFactory {
 	return &routerFactoryImpl{}
 }
 
+// NewServeMuxRouterFactory instantiates a new RouterFactory implementation, which creates routers based on the
+// http.ServeMux of the standard library.
+func NewServeMuxRouterFactory() RouterFactory {
+	return &serveMuxRouterFactoryImpl{}
+}
+
+/* RouterParams implementation */
+
+// ByName returns the value of the path parameter with the specified name, or an empty string if it does not exist.
+func (p RouterParams) ByName(name string) string {
+	return p.Params.ByName(name)
+}
+
 /* RouterFactory implementation */
 
-func (r *routerFactoryImpl) NewRouter() *Router {
-	return &Router{Router: httprouter.New()}
+func (f *routerFactoryImpl) NewRouter() Router {
+	return &httpRouterImpl{router: httprouter.New()}
+}
+
+func (f *serveMuxRouterFactoryImpl) NewRouter() Router {
+	return &serveMuxRouterImpl{mux: http.NewServeMux(), methods: make(map[string]bool)}
+}
+
+/* Router implementation (httprouter) */
+
+func (r *httpRouterImpl) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.router.ServeHTTP(w, req)
+}
+
+func (r *httpRouterImpl) Handle(method, path string, handle RouterHandle) {
+	r.router.Handle(method, path, func(w http.ResponseWriter, req *http.Request, p httprouter.Params) {
+		handle(w, req, RouterParams{Params: p})
+	})
+}
+
+func (r *httpRouterImpl) NotFound(handler http.Handler) {
+	r.router.NotFound = handler
+}
+
+/* Router implementation (http.ServeMux) */
+
+func (r *serveMuxRouterImpl) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if r.notFound != nil {
+		if _, pattern := r.mux.Handler(req); pattern == "" && !r.matchesOtherMethod(req) {
+			r.notFound.ServeHTTP(w, req)
+			return
+		}
+	}
+	// Requests matching the path for another method are answered by http.ServeMux with 405, like httprouter.
+	r.mux.ServeHTTP(w, req)
+}
+
+func (r *serveMuxRouterImpl) Handle(method, path string, handle RouterHandle) {
+	pattern, names, catchAll := toServeMuxPattern(path)
+	r.methods[method] = true
+
+	r.mux.HandleFunc(method+" "+pattern, func(w http.ResponseWriter, req *http.Request) {
+		var params httprouter.Params
+
+		for _, name := range names {
+			value := req.PathValue(name)
+			if name == catchAll {
+				// Align with httprouter, which includes the leading slash in catch-all parameters.
+				value = "/" + value
+			}
+			params = append(params, httprouter.Param{Key: name, Value: value})
+		}
+		handle(w, req, RouterParams{Params: params})
+	})
+}
+
+func (r *serveMuxRouterImpl) NotFound(handler http.Handler) {
+	r.notFound = handler
+}
+
+// matchesOtherMethod returns whether the request path is registered for another method.
+func (r *serveMuxRouterImpl) matchesOtherMethod(req *http.Request) bool {
+	for method := range r.methods {
+		if method == req.Method {
+			continue
+		}
+
+		probe := req.Clone(req.Context())
+		probe.Method = method
+		if _, pattern := r.mux.Handler(probe); pattern != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// toServeMuxPattern translates a path in httprouter syntax to a http.ServeMux pattern and returns the names of the
+// parameters and the name of the catch-all parameter, if any.
+func toServeMuxPattern(path string) (string, []string, string) {
+	segments := strings.Split(path, "/")
+	var names []string
+	catchAll := ""
+
+	for i, segment := range segments {
+		switch {
+		case strings.HasPrefix(segment, ":"):
+			names = append(names, segment[1:])
+			segments[i] = "{" + segment[1:] + "}"
+		case strings.HasPrefix(segment, "*"):
+			catchAll = segment[1:]
+			names = append(names, catchAll)
+			segments[i] = "{" + catchAll + "...}"
+		}
+	}
+
+	pattern := strings.Join(segments, "/")
+
+	if catchAll == "" && strings.HasSuffix(pattern, "/") {
+		// httprouter only matches the exact path, while http.ServeMux treats a trailing slash as a prefix.
+		pattern += "{$}"
+	}
+	return pattern, names, catchAll
 }
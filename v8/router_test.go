@@ -1,9 +1,12 @@
 package v8_test
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	sf "github.com/Travix-International/go-servicefoundation/v8"
+	"github.com/julienschmidt/httprouter"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -16,3 +19,66 @@ func TestNewRouterFactory(t *testing.T) {
 
 	assert.NotNil(t, actual)
 }
+
+func TestNewServeMuxRouterFactory(t *testing.T) {
+	sut := sf.NewServeMuxRouterFactory()
+
+	assert.NotNil(t, sut)
+
+	actual := sut.NewRouter()
+
+	assert.NotNil(t, actual)
+}
+
+func TestRouter_Handle(t *testing.T) {
+	scenarios := map[string]sf.RouterFactory{
+		"httprouter": sf.NewRouterFactory(),
+		"servemux":   sf.NewServeMuxRouterFactory(),
+	}
+
+	for name, factory := range scenarios {
+		var actualID, actualPath string
+		var actualParams httprouter.Params
+		sut := factory.NewRouter()
+
+		sut.Handle(http.MethodGet, "/", func(w http.ResponseWriter, _ *http.Request, _ sf.RouterParams) {
+			w.WriteHeader(http.StatusAccepted)
+		})
+		sut.Handle(http.MethodGet, "/users/:id", func(w http.ResponseWriter, _ *http.Request, p sf.RouterParams) {
+			actualID = p.ByName("id")
+			actualParams = p.Params
+		})
+		sut.Handle(http.MethodGet, "/files/*filepath", func(w http.ResponseWriter, _ *http.Request, p sf.RouterParams) {
+			actualPath = p.ByName("filepath")
+		})
+		sut.NotFound(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}))
+
+		// Act
+		root := httptest.NewRecorder()
+		sut.ServeHTTP(root, httptest.NewRequest(http.MethodGet, "/", nil))
+		sut.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/42", nil))
+		sut.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/files/css/site.css", nil))
+		notFound := httptest.NewRecorder()
+		sut.ServeHTTP(notFound, httptest.NewRequest(http.MethodGet, "/unknown", nil))
+		notAllowed := httptest.NewRecorder()
+		sut.ServeHTTP(notAllowed, httptest.NewRequest(http.MethodPost, "/users/42", nil))
+
+		assert.Equal(t, http.StatusAccepted, root.Code, name)
+		assert.Equal(t, "42", actualID, name)
+		assert.Equal(t, "/css/site.css", actualPath, name)
+		assert.Equal(t, http.StatusTeapot, notFound.Code, name)
+		assert.Equal(t, http.StatusMethodNotAllowed, notAllowed.Code, name)
+		assert.Equal(t, httprouter.Params{{Key: "id", Value: "42"}}, actualParams, name)
+	}
+}
+
+func TestRouterParams_ByName_WithoutParams(t *testing.T) {
+	sut := sf.RouterParams{}
+
+	// Act
+	actual := sut.ByName("id")
+
+	assert.Equal(t, "", actual)
+}
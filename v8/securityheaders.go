@@ -0,0 +1,382 @@
+package v8
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultHSTSMaxAge     = 365 * 24 * time.Hour
+	defaultFrameOptions   = "DENY"
+	defaultReferrerPolicy = "strict-origin-when-cross-origin"
+	maxCSPReportBytes     = 64 << 10
+	maxCSPReports         = 20
+	cspWarningsPerSecond  = 10
+
+	// CSPReportPath is the path of the public endpoint collecting Content-Security-Policy violation reports.
+	CSPReportPath = "/csp-report"
+
+	// CSPSelf is the Content-Security-Policy source matching the origin of the document.
+	CSPSelf = "'self'"
+	// CSPNone is the Content-Security-Policy source matching nothing.
+	CSPNone = "'none'"
+	// CSPUnsafeInline is the Content-Security-Policy source allowing inline scripts or styles.
+	CSPUnsafeInline = "'unsafe-inline'"
+	// CSPStrictDynamic is the Content-Security-Policy source trusting scripts loaded by nonced scripts.
+	CSPStrictDynamic = "'strict-dynamic'"
+	// CSPNonce is the Content-Security-Policy source replaced by a nonce generated per request, available through
+	// CSPNonceFromContext.
+	CSPNonce = "'nonce'"
+)
+
+type (
+	// SecurityHeadersOptions contains properties used by the SecurityHeaders middleware.
+	SecurityHeadersOptions struct {
+		// HSTSMaxAge is the max-age of the Strict-Transport-Security header. Default value is 365 days.
+		HSTSMaxAge time.Duration
+		// HSTSIncludeSubdomains applies Strict-Transport-Security to all subdomains.
+		HSTSIncludeSubdomains bool
+		// HSTSPreload allows the domain to be included in the HSTS preload lists of browsers.
+		HSTSPreload bool
+		// DisableHSTS omits the Strict-Transport-Security header, for services not served over https.
+		DisableHSTS bool
+		// FrameOptions is the value of the X-Frame-Options header. Default value is "DENY".
+		FrameOptions string
+		// ReferrerPolicy is the value of the Referrer-Policy header. Default value is
+		// "strict-origin-when-cross-origin".
+		ReferrerPolicy string
+		// PermissionsPolicy is the value of the Permissions-Policy header, like "camera=(), geolocation=()". The
+		// header is omitted when empty.
+		PermissionsPolicy string
+		// ContentSecurityPolicy is the policy of the Content-Security-Policy header. The header is omitted when nil.
+		ContentSecurityPolicy *CSP
+		// CSPReportOnly sends the policy in the Content-Security-Policy-Report-Only header, so violations are reported
+		// but not blocked. The policy must contain a report-uri or report-to directive, like ReportURI(CSPReportPath)
+		// together with ServiceOptions.UseCSPReportHandler.
+		CSPReportOnly bool
+	}
+
+	// CSP is a builder for Content-Security-Policy headers. Directives are written in the order they are added.
+	CSP struct {
+		names   []string
+		sources map[string][]string
+	}
+
+	// CSPViolation contains the properties of a Content-Security-Policy violation report.
+	CSPViolation struct {
+		DocumentURI        string `json:"document-uri"`
+		Referrer           string `json:"referrer"`
+		ViolatedDirective  string `json:"violated-directive"`
+		EffectiveDirective string `json:"effective-directive"`
+		BlockedURI         string `json:"blocked-uri"`
+		SourceFile         string `json:"source-file"`
+		LineNumber         int    `json:"line-number"`
+		Disposition        string `json:"disposition"`
+	}
+
+	// cspReport is the report format of the report-uri directive.
+	cspReport struct {
+		Report CSPViolation `json:"csp-report"`
+	}
+
+	// reportingAPIReport is the report format of the Reporting API, used by the report-to directive.
+	reportingAPIReport struct {
+		Type string `json:"type"`
+		Body struct {
+			DocumentURL        string `json:"documentURL"`
+			Referrer           string `json:"referrer"`
+			EffectiveDirective string `json:"effectiveDirective"`
+			BlockedURL         string `json:"blockedURL"`
+			SourceFile         string `json:"sourceFile"`
+			LineNumber         int    `json:"lineNumber"`
+			Disposition        string `json:"disposition"`
+		} `json:"body"`
+	}
+
+	cspNonceKey struct{}
+)
+
+// cspDirectives contains the directives counted by name in the violation metrics; others are counted as "other".
+var cspDirectives = map[string]bool{
+	"base-uri": true, "child-src": true, "connect-src": true, "default-src": true, "font-src": true,
+	"form-action": true, "frame-ancestors": true, "frame-src": true, "img-src": true, "manifest-src": true,
+	"media-src": true, "object-src": true, "script-src": true, "script-src-attr": true, "script-src-elem": true,
+	"style-src": true, "style-src-attr": true, "style-src-elem": true, "worker-src": true,
+	"require-trusted-types-for": true, "trusted-types": true, "sandbox": true,
+}
+
+// NewCSP instantiates a new, empty Content-Security-Policy builder.
+func NewCSP() *CSP {
+	return &CSP{sources: make(map[string][]string)}
+}
+
+// Directive adds the sources to the directive with the specified name.
+func (c *CSP) Directive(name string, sources ...string) *CSP {
+	name = strings.ToLower(name)
+	if _, exists := c.sources[name]; !exists {
+		c.names = append(c.names, name)
+	}
+	c.sources[name] = append(c.sources[name], sources...)
+	return c
+}
+
+// DefaultSrc adds sources to the default-src directive.
+func (c *CSP) DefaultSrc(sources ...string) *CSP {
+	return c.Directive("default-src", sources...)
+}
+
+// ScriptSrc adds sources to the script-src directive.
+func (c *CSP) ScriptSrc(sources ...string) *CSP {
+	return c.Directive("script-src", sources...)
+}
+
+// StyleSrc adds sources to the style-src directive.
+func (c *CSP) StyleSrc(sources ...string) *CSP {
+	return c.Directive("style-src", sources...)
+}
+
+// ImgSrc adds sources to the img-src directive.
+func (c *CSP) ImgSrc(sources ...string) *CSP {
+	return c.Directive("img-src", sources...)
+}
+
+// ConnectSrc adds sources to the connect-src directive.
+func (c *CSP) ConnectSrc(sources ...string) *CSP {
+	return c.Directive("connect-src", sources...)
+}
+
+// FontSrc adds sources to the font-src directive.
+func (c *CSP) FontSrc(sources ...string) *CSP {
+	return c.Directive("font-src", sources...)
+}
+
+// ObjectSrc adds sources to the object-src directive.
+func (c *CSP) ObjectSrc(sources ...string) *CSP {
+	return c.Directive("object-src", sources...)
+}
+
+// FrameSrc adds sources to the frame-src directive.
+func (c *CSP) FrameSrc(sources ...string) *CSP {
+	return c.Directive("frame-src", sources...)
+}
+
+// FrameAncestors adds sources to the frame-ancestors directive.
+func (c *CSP) FrameAncestors(sources ...string) *CSP {
+	return c.Directive("frame-ancestors", sources...)
+}
+
+// BaseURI adds sources to the base-uri directive.
+func (c *CSP) BaseURI(sources ...string) *CSP {
+	return c.Directive("base-uri", sources...)
+}
+
+// FormAction adds sources to the form-action directive.
+func (c *CSP) FormAction(sources ...string) *CSP {
+	return c.Directive("form-action", sources...)
+}
+
+// UpgradeInsecureRequests adds the upgrade-insecure-requests directive.
+func (c *CSP) UpgradeInsecureRequests() *CSP {
+	return c.Directive("upgrade-insecure-requests")
+}
+
+// ReportURI adds the report-uri directive.
+func (c *CSP) ReportURI(uri string) *CSP {
+	return c.Directive("report-uri", uri)
+}
+
+// ReportTo adds the report-to directive with the name of a Reporting API endpoint.
+func (c *CSP) ReportTo(group string) *CSP {
+	return c.Directive("report-to", group)
+}
+
+// String returns the policy as header value, with CSPNonce placeholders for the per-request nonces.
+func (c *CSP) String() string {
+	directives := make([]string, 0, len(c.names))
+	for _, name := range c.names {
+		directives = append(directives, strings.Join(append([]string{name}, c.sources[name]...), " "))
+	}
+	return strings.Join(directives, "; ")
+}
+
+func (c *CSP) has(name string) bool {
+	_, exists := c.sources[name]
+	return exists
+}
+
+// SecurityHeaders returns a Middleware enumeration that sets Strict-Transport-Security, X-Content-Type-Options,
+// X-Frame-Options, Referrer-Policy, Permissions-Policy and Content-Security-Policy headers using the specified options.
+// Handlers can override the headers. The header values are built once, so only a per-request CSPNonce is generated
+// when serving requests. It panics when a report-only policy has no report-uri or report-to directive.
+func SecurityHeaders(options SecurityHeadersOptions) Middleware {
+	if options.HSTSMaxAge <= 0 {
+		options.HSTSMaxAge = defaultHSTSMaxAge
+	}
+	if options.FrameOptions == "" {
+		options.FrameOptions = defaultFrameOptions
+	}
+	if options.ReferrerPolicy == "" {
+		options.ReferrerPolicy = defaultReferrerPolicy
+	}
+
+	headers := make(http.Header)
+	if !options.DisableHSTS {
+		hsts := "max-age=" + strconv.Itoa(int(options.HSTSMaxAge.Seconds()))
+		if options.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if options.HSTSPreload {
+			hsts += "; preload"
+		}
+		headers.Set("Strict-Transport-Security", hsts)
+	}
+	headers.Set("X-Content-Type-Options", "nosniff")
+	headers.Set("X-Frame-Options", options.FrameOptions)
+	headers.Set("Referrer-Policy", options.ReferrerPolicy)
+	if options.PermissionsPolicy != "" {
+		headers.Set("Permissions-Policy", options.PermissionsPolicy)
+	}
+
+	var policy, policyHeader string
+	if csp := options.ContentSecurityPolicy; csp != nil {
+		policy = csp.String()
+		policyHeader = "Content-Security-Policy"
+
+		if options.CSPReportOnly {
+			if !csp.has("report-uri") && !csp.has("report-to") {
+				panic(fmt.Errorf("Invalid report-only policy without report-uri or report-to: %s", policy))
+			}
+			policyHeader = "Content-Security-Policy-Report-Only"
+		}
+	}
+
+	return registerMiddleware("", func(m *middlewareWrapperImpl, subsystem, name string, handler Handle,
+		_ MetaFunc) Handle {
+
+		return m.wrapWithSecurityHeaders(handler, headers, policyHeader, policy)
+	})
+}
+
+// CSPNonceFromContext returns the nonce generated for the CSPNonce sources of the request, or an empty string when
+// absent.
+func CSPNonceFromContext(ctx context.Context) string {
+	nonce, _ := ctx.Value(cspNonceKey{}).(string)
+	return nonce
+}
+
+func (m *middlewareWrapperImpl) wrapWithSecurityHeaders(handler Handle, headers http.Header, policyHeader,
+	policy string) Handle {
+
+	usesNonce := strings.Contains(policy, CSPNonce)
+
+	return func(w WrappedResponseWriter, r *http.Request, p RouterParams) {
+		h := w.Header()
+		for key, values := range headers {
+			h[key] = append([]string(nil), values...)
+		}
+
+		if policyHeader != "" {
+			if usesNonce {
+				nonce := newCSPNonce()
+				r = r.WithContext(context.WithValue(r.Context(), cspNonceKey{}, nonce))
+				h.Set(policyHeader, strings.ReplaceAll(policy, CSPNonce, "'nonce-"+nonce+"'"))
+			} else {
+				h.Set(policyHeader, policy)
+			}
+		}
+
+		handler(w, r, p)
+	}
+}
+
+func newCSPNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// NewCSPReportHandler creates a Handle that logs the Content-Security-Policy violation reports sent by browsers, in
+// the report-uri or the Reporting API format, and counts them per directive. As the endpoint is public, only the
+// first reports of a request are handled and the warnings are limited to a few per second.
+func NewCSPReportHandler(log Logger, metrics Metrics) Handle {
+	warnings := NewMemoryRateLimitStore(1, time.Hour)
+
+	return func(w WrappedResponseWriter, r *http.Request, _ RouterParams) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxCSPReportBytes))
+		if err != nil {
+			writeErrorResponse(w, r, http.StatusBadRequest, "Invalid CSP report")
+			return
+		}
+
+		violations, err := parseCSPReports(body)
+		if err != nil {
+			writeErrorResponse(w, r, http.StatusBadRequest, "Invalid CSP report")
+			return
+		}
+
+		if len(violations) > maxCSPReports {
+			violations = violations[:maxCSPReports]
+		}
+
+		for _, v := range violations {
+			directive := v.EffectiveDirective
+			if directive == "" {
+				directive, _, _ = strings.Cut(v.ViolatedDirective, " ")
+			}
+			if directive = strings.ToLower(directive); !cspDirectives[directive] {
+				directive = "other"
+			}
+
+			metrics.CountLabels("", "http_csp_violations_total", "Total reported Content-Security-Policy violations.",
+				[]string{"directive"}, []string{directive})
+			if !warnings.Take("", cspWarningsPerSecond, cspWarningsPerSecond).Allowed {
+				continue
+			}
+			log.Warn("CSPViolation", "Directive %s blocked %s on %s (%s:%d, %s)", directive, v.BlockedURI,
+				v.DocumentURI, v.SourceFile, v.LineNumber, v.Disposition)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// parseCSPReports parses a report-uri report or a list of Reporting API reports.
+func parseCSPReports(body []byte) ([]CSPViolation, error) {
+	if trimmed := strings.TrimSpace(string(body)); strings.HasPrefix(trimmed, "[") {
+		var reports []reportingAPIReport
+		if err := json.Unmarshal(body, &reports); err != nil {
+			return nil, err
+		}
+
+		var violations []CSPViolation
+		for _, report := range reports {
+			if report.Type != "csp-violation" {
+				continue
+			}
+			violations = append(violations, CSPViolation{
+				DocumentURI:        report.Body.DocumentURL,
+				Referrer:           report.Body.Referrer,
+				EffectiveDirective: report.Body.EffectiveDirective,
+				BlockedURI:         report.Body.BlockedURL,
+				SourceFile:         report.Body.SourceFile,
+				LineNumber:         report.Body.LineNumber,
+				Disposition:        report.Body.Disposition,
+			})
+		}
+		return violations, nil
+	}
+
+	var report cspReport
+	if err := json.Unmarshal(body, &report); err != nil {
+		return nil, err
+	}
+	return []CSPViolation{report.Report}, nil
+}
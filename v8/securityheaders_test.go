@@ -0,0 +1,216 @@
+package v8_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	sf "github.com/Travix-International/go-servicefoundation/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCSP_String(t *testing.T) {
+	scenarios := []struct {
+		csp      *sf.CSP
+		expected string
+	}{
+		{sf.NewCSP(), ""},
+		{sf.NewCSP().DefaultSrc(sf.CSPSelf), "default-src 'self'"},
+		{sf.NewCSP().DefaultSrc(sf.CSPNone).ScriptSrc(sf.CSPSelf, "https://cdn.sf.com").ScriptSrc(sf.CSPNonce).
+			FrameAncestors(sf.CSPNone).UpgradeInsecureRequests(),
+			"default-src 'none'; script-src 'self' https://cdn.sf.com 'nonce'; frame-ancestors 'none'; " +
+				"upgrade-insecure-requests"},
+		{sf.NewCSP().Directive("Worker-Src", sf.CSPSelf).ReportURI("/reports"), "worker-src 'self'; report-uri /reports"},
+	}
+
+	for i, scenario := range scenarios {
+		// Act
+		actual := scenario.csp.String()
+
+		assert.Equal(t, scenario.expected, actual, "Scenario %d", i)
+	}
+}
+
+func TestMiddlewareWrapperImpl_Wrap_SecurityHeaders(t *testing.T) {
+	scenarios := []struct {
+		options  sf.SecurityHeadersOptions
+		expected map[string]string
+	}{
+		{sf.SecurityHeadersOptions{}, map[string]string{
+			"Strict-Transport-Security": "max-age=31536000",
+			"X-Content-Type-Options":    "nosniff",
+			"X-Frame-Options":           "DENY",
+			"Referrer-Policy":           "strict-origin-when-cross-origin",
+			"Permissions-Policy":        "",
+			"Content-Security-Policy":   "",
+		}},
+		{sf.SecurityHeadersOptions{
+			HSTSMaxAge:            time.Hour,
+			HSTSIncludeSubdomains: true,
+			HSTSPreload:           true,
+			FrameOptions:          "SAMEORIGIN",
+			ReferrerPolicy:        "no-referrer",
+			PermissionsPolicy:     "camera=()",
+			ContentSecurityPolicy: sf.NewCSP().DefaultSrc(sf.CSPSelf),
+		}, map[string]string{
+			"Strict-Transport-Security": "max-age=3600; includeSubDomains; preload",
+			"X-Frame-Options":           "SAMEORIGIN",
+			"Referrer-Policy":           "no-referrer",
+			"Permissions-Policy":        "camera=()",
+			"Content-Security-Policy":   "default-src 'self'",
+		}},
+		{sf.SecurityHeadersOptions{
+			DisableHSTS:           true,
+			ContentSecurityPolicy: sf.NewCSP().DefaultSrc(sf.CSPSelf).ReportURI(sf.CSPReportPath),
+			CSPReportOnly:         true,
+		}, map[string]string{
+			"Strict-Transport-Security":           "",
+			"Content-Security-Policy":             "",
+			"Content-Security-Policy-Report-Only": "default-src 'self'; report-uri /csp-report",
+		}},
+		{sf.SecurityHeadersOptions{
+			ContentSecurityPolicy: sf.NewCSP().DefaultSrc(sf.CSPSelf).ReportTo("csp"),
+			CSPReportOnly:         true,
+		}, map[string]string{
+			"Content-Security-Policy-Report-Only": "default-src 'self'; report-to csp",
+		}},
+	}
+
+	for i, scenario := range scenarios {
+		logFactory := &mockLogFactory{}
+		log := &mockLogger{}
+		m := &mockMetrics{}
+		handle := func(w sf.WrappedResponseWriter, _ *http.Request, _ sf.RouterParams) {
+			w.WriteHeader(http.StatusOK)
+		}
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "https://www.sf.com/page", nil)
+
+		logFactory.On("NewLogger", mock.Anything).Return(log)
+
+		sut := sf.NewMiddlewareWrapper(logFactory, m, &sf.CORSOptions{}, sf.ServiceGlobals{})
+
+		// Act
+		sut.Wrap("my-sub", "my-name", sf.SecurityHeaders(scenario.options), handle, nil)(
+			sf.NewWrappedResponseWriter(w), r, sf.RouterParams{})
+
+		for header, expected := range scenario.expected {
+			assert.Equal(t, expected, w.Header().Get(header), "Scenario %d: %s", i, header)
+		}
+	}
+}
+
+func TestMiddlewareWrapperImpl_Wrap_SecurityHeadersNonce(t *testing.T) {
+	logFactory := &mockLogFactory{}
+	log := &mockLogger{}
+	m := &mockMetrics{}
+	var nonces []string
+	handle := func(w sf.WrappedResponseWriter, r *http.Request, _ sf.RouterParams) {
+		nonces = append(nonces, sf.CSPNonceFromContext(r.Context()))
+		w.WriteHeader(http.StatusOK)
+	}
+
+	logFactory.On("NewLogger", mock.Anything).Return(log)
+
+	sut := sf.NewMiddlewareWrapper(logFactory, m, &sf.CORSOptions{}, sf.ServiceGlobals{})
+	options := sf.SecurityHeadersOptions{
+		ContentSecurityPolicy: sf.NewCSP().ScriptSrc(sf.CSPNonce, sf.CSPStrictDynamic).StyleSrc(sf.CSPNonce),
+	}
+	wrapped := sut.Wrap("my-sub", "my-name", sf.SecurityHeaders(options), handle, nil)
+
+	var policies []string
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "https://www.sf.com/page", nil)
+
+		// Act
+		wrapped(sf.NewWrappedResponseWriter(w), r, sf.RouterParams{})
+
+		policies = append(policies, w.Header().Get("Content-Security-Policy"))
+	}
+
+	pattern := regexp.MustCompile(`^script-src 'nonce-([A-Za-z0-9+/=]{24})' 'strict-dynamic'; style-src 'nonce-([A-Za-z0-9+/=]{24})'$`)
+	for i, policy := range policies {
+		match := pattern.FindStringSubmatch(policy)
+		if assert.NotNil(t, match, "Request %d: %s", i, policy) {
+			assert.Equal(t, nonces[i], match[1], "Request %d", i)
+			assert.Equal(t, nonces[i], match[2], "Request %d", i)
+		}
+	}
+	assert.NotEqual(t, nonces[0], nonces[1])
+}
+
+func TestSecurityHeaders_ReportOnlyWithoutReportURI(t *testing.T) {
+	options := sf.SecurityHeadersOptions{ContentSecurityPolicy: sf.NewCSP().DefaultSrc(sf.CSPSelf), CSPReportOnly: true}
+
+	// Act
+	assert.Panics(t, func() { sf.SecurityHeaders(options) })
+}
+
+func TestNewCSPReportHandler(t *testing.T) {
+	scenarios := []struct {
+		body               string
+		expectedStatus     int
+		expectedDirectives []string
+	}{
+		{`{"csp-report":{"document-uri":"https://www.sf.com/page","violated-directive":"script-src 'self'",` +
+			`"blocked-uri":"https://evil.com/x.js","disposition":"report"}}`, http.StatusNoContent, []string{"script-src"}},
+		{`[{"type":"csp-violation","body":{"documentURL":"https://www.sf.com/page","effectiveDirective":"img-src",` +
+			`"blockedURL":"https://evil.com/x.png"}},{"type":"deprecation","body":{}}]`, http.StatusNoContent,
+			[]string{"img-src"}},
+		{`{"csp-report":{"violated-directive":"made-up-directive-1234 'self'"}}`, http.StatusNoContent,
+			[]string{"other"}},
+		{`not json`, http.StatusBadRequest, nil},
+	}
+
+	for i, scenario := range scenarios {
+		log := &mockLogger{}
+		m := &mockMetrics{}
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "https://www.sf.com/csp-report", strings.NewReader(scenario.body))
+		r.Header.Set("Content-Type", "application/csp-report")
+
+		log.On("Warn", "CSPViolation", mock.Anything, mock.Anything).Return()
+		m.On("CountLabels", "", "http_csp_violations_total", mock.Anything, []string{"directive"}, mock.Anything).
+			Return()
+
+		sut := sf.NewCSPReportHandler(log, m)
+
+		// Act
+		sut(sf.NewWrappedResponseWriter(w), r, sf.RouterParams{})
+
+		assert.Equal(t, scenario.expectedStatus, w.Code, "Scenario %d", i)
+		m.AssertNumberOfCalls(t, "CountLabels", len(scenario.expectedDirectives))
+		for _, directive := range scenario.expectedDirectives {
+			m.AssertCalled(t, "CountLabels", "", "http_csp_violations_total", mock.Anything, []string{"directive"},
+				[]string{directive})
+		}
+		log.AssertNumberOfCalls(t, "Warn", len(scenario.expectedDirectives))
+	}
+}
+
+func TestNewCSPReportHandler_Limits(t *testing.T) {
+	log := &mockLogger{}
+	m := &mockMetrics{}
+	report := `{"type":"csp-violation","body":{"effectiveDirective":"img-src","blockedURL":"https://evil.com/x.png"}}`
+	body := "[" + strings.TrimSuffix(strings.Repeat(report+",", 30), ",") + "]"
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "https://www.sf.com/csp-report", strings.NewReader(body))
+
+	log.On("Warn", "CSPViolation", mock.Anything, mock.Anything).Return()
+	m.On("CountLabels", "", "http_csp_violations_total", mock.Anything, []string{"directive"}, mock.Anything).
+		Return()
+
+	sut := sf.NewCSPReportHandler(log, m)
+
+	// Act
+	sut(sf.NewWrappedResponseWriter(w), r, sf.RouterParams{})
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	m.AssertNumberOfCalls(t, "CountLabels", 20)
+	log.AssertNumberOfCalls(t, "Warn", 10)
+}
@@ -3,9 +3,11 @@ package v8
 import (
 	"context"
 	"fmt"
+	"io/fs"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -23,13 +25,30 @@ const (
 	defaultHTTPPort     = 8080
 	defaultLogMinFilter = "Warning"
 
-	publicSubsystem = "public"
+	// PublicSubsystem is the name of the subsystem serving the public endpoints.
+	PublicSubsystem = "public"
+	// ReadinessSubsystem is the name of the subsystem serving the liveness and readiness endpoints.
+	ReadinessSubsystem = "readiness"
+	// InternalSubsystem is the name of the subsystem serving the internal endpoints, like metrics.
+	InternalSubsystem = "internal"
+)
+
+const (
+	// InnerToOuter is a MiddlewareOrder that applies the first middleware of a slice as the innermost wrapper, so
+	// middleware runs in the reverse order of the slice. This is the default.
+	InnerToOuter MiddlewareOrder = 0
+	// OuterToInner is a MiddlewareOrder that applies the first middleware of a slice as the outermost wrapper, so
+	// middleware runs in the order of the slice.
+	OuterToInner MiddlewareOrder = 1
 )
 
 type (
 	// ShutdownFunc is a function signature for the shutdown function.
 	ShutdownFunc func(log Logger)
 
+	// MiddlewareOrder is an enumeration to indicate how the middleware slices passed to a Service are applied.
+	MiddlewareOrder int
+
 	// ServiceGlobals contains basic service properties, like name, deployment environment and version number.
 	ServiceGlobals struct {
 		AppName           string
@@ -59,6 +78,19 @@ type (
 		ServerTimeout        time.Duration
 		IdleTimeout          time.Duration
 		UsePublicRootHandler bool
+		// UseCSPReportHandler adds a public endpoint at CSPReportPath that logs Content-Security-Policy violations.
+		UseCSPReportHandler bool
+		// MiddlewareOrder indicates how middleware slices are applied. Default value is InnerToOuter.
+		MiddlewareOrder MiddlewareOrder
+		// GlobalMiddlewares contains middleware applied to every public route, outside the middleware of the route.
+		GlobalMiddlewares []Middleware
+		// GlobalMiddlewareExclusions contains the names of the public routes GlobalMiddlewares is not applied to.
+		GlobalMiddlewareExclusions []string
+		// SubsystemMiddlewares contains the middleware for the predefined endpoints per subsystem. Subsystems
+		// without an entry use DefaultMiddlewares.
+		SubsystemMiddlewares map[string][]Middleware
+		// ResponseCaches contains the response caches that can be purged with DELETE /cache on the internal server.
+		ResponseCaches []ResponseCache
 	}
 
 	// ServiceStateReader contains state methods used by the service's handler implementations.
@@ -72,6 +104,10 @@ type (
 	Service interface {
 		Run(ctx context.Context)
 		AddRoute(name string, routes []string, methods []string, middlewares []Middleware, metaFunc MetaFunc, handler Handle)
+		AddReadinessRoute(name string, routes []string, methods []string, middlewares []Middleware, metaFunc MetaFunc, handler Handle)
+		AddInternalRoute(name string, routes []string, methods []string, middlewares []Middleware, metaFunc MetaFunc, handler Handle)
+		Mount(subsystem, prefix string, handler http.Handler, middlewares ...Middleware) error
+		AddStaticRoute(prefix string, fsys fs.FS, options StaticOptions) error
 	}
 
 	serviceStateReaderImpl struct {
@@ -87,9 +123,9 @@ type (
 		logFactory           LogFactory
 		log                  Logger
 		metrics              Metrics
-		publicRouter         *Router
-		readinessRouter      *Router
-		internalRouter       *Router
+		publicRouter         Router
+		readinessRouter      Router
+		internalRouter       Router
 		handlers             *Handlers
 		wrapHandler          WrapHandler
 		versionBuilder       VersionBuilder
@@ -100,9 +136,19 @@ type (
 		sendChan             chan bool
 		receiveChan          chan bool
 		usePublicRootHandler bool
+		useCSPReportHandler  bool
+		middlewareOrder      MiddlewareOrder
+		globalMiddlewares    []Middleware
+		globalExclusions     map[string]bool
+		subsystemMiddlewares map[string][]Middleware
+		responseCaches       []ResponseCache
 	}
 )
 
+// mountMethods contains the http methods that are routed to handlers mounted with Service.Mount.
+var mountMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodOptions}
+
 // DefaultMiddlewares contains the default middleware wrappers for the predefined service endpoints.
 var DefaultMiddlewares = []Middleware{PanicTo500, NoCaching}
 
@@ -176,6 +222,12 @@ func createServiceMeta(baseMeta map[string]string, globals ServiceGlobals) map[s
 
 // NewCustomService allows you to customize ServiceFoundation using your own implementations of factories.
 func NewCustomService(options ServiceOptions) Service {
+	globalExclusions := make(map[string]bool)
+
+	for _, name := range options.GlobalMiddlewareExclusions {
+		globalExclusions[name] = true
+	}
+
 	return &serviceImpl{
 		globals:              options.Globals,
 		serverTimeout:        options.ServerTimeout,
@@ -197,6 +249,12 @@ func NewCustomService(options ServiceOptions) Service {
 		sendChan:             make(chan bool, 1),
 		receiveChan:          make(chan bool, 1),
 		usePublicRootHandler: options.UsePublicRootHandler,
+		useCSPReportHandler:  options.UseCSPReportHandler,
+		middlewareOrder:      options.MiddlewareOrder,
+		globalMiddlewares:    options.GlobalMiddlewares,
+		globalExclusions:     globalExclusions,
+		subsystemMiddlewares: options.SubsystemMiddlewares,
+		responseCaches:       options.ResponseCaches,
 	}
 }
 
@@ -302,32 +360,172 @@ func (s *serviceImpl) Run(ctx context.Context) {
 }
 
 func (s *serviceImpl) AddRoute(name string, routes []string, methods []string, middlewares []Middleware, metaFunc MetaFunc, handler Handle) {
-	s.addRouteWithMetaAndPreFlight(s.publicRouter, publicSubsystem, name, routes, methods, middlewares, metaFunc, handler)
+	s.addRouteWithMetaAndPreFlight(s.publicRouter, PublicSubsystem, name, routes, methods, middlewares, metaFunc, handler)
 }
 
-func (s *serviceImpl) addRoute(router *Router, subsystem, name string, routes []string, methods []string, middlewares []Middleware, handler Handle) {
+func (s *serviceImpl) AddReadinessRoute(name string, routes []string, methods []string, middlewares []Middleware, metaFunc MetaFunc, handler Handle) {
+	if routes = s.withoutPredefinedPaths(ReadinessSubsystem, name, routes); len(routes) > 0 {
+		s.addRouteWithMetaAndPreFlight(s.readinessRouter, ReadinessSubsystem, name, routes, methods, middlewares, metaFunc, handler)
+	}
+}
+
+func (s *serviceImpl) AddInternalRoute(name string, routes []string, methods []string, middlewares []Middleware, metaFunc MetaFunc, handler Handle) {
+	if routes = s.withoutPredefinedPaths(InternalSubsystem, name, routes); len(routes) > 0 {
+		s.addRouteWithMetaAndPreFlight(s.internalRouter, InternalSubsystem, name, routes, methods, middlewares, metaFunc, handler)
+	}
+}
+
+// Mount routes all requests below the specified prefix to a standard http.Handler on the router of the specified
+// subsystem. The request path is passed as-is, so use http.StripPrefix if the handler expects relative paths. An error
+// is returned for an unknown subsystem, or a prefix that conflicts with the routes of the subsystem.
+func (s *serviceImpl) Mount(subsystem, prefix string, handler http.Handler, middlewares ...Middleware) error {
+	name := strings.ReplaceAll(strings.Trim(prefix, "/"), "/", "_")
+
+	return s.addPrefixRoute(subsystem, name, prefix, mountMethods, middlewares, FromHTTPHandler(handler))
+}
+
+func (s *serviceImpl) AddStaticRoute(prefix string, fsys fs.FS, options StaticOptions) error {
+	subsystem := options.Subsystem
+	if subsystem == "" {
+		subsystem = PublicSubsystem
+	}
+
+	name := options.Name
+	if name == "" {
+		name = "static"
+	}
+
+	return s.addPrefixRoute(subsystem, name, prefix, []string{http.MethodGet, http.MethodHead}, options.Middlewares,
+		NewStaticHandler(fsys, options))
+}
+
+// addPrefixRoute adds a catch-all route below the prefix. Conflicts with the predefined routes of the subsystem, which
+// are added when the service runs, and with the routes added before are returned as an error.
+func (s *serviceImpl) addPrefixRoute(subsystem, name, prefix string, methods []string, middlewares []Middleware,
+	handler Handle) (err error) {
+
+	router, err := s.getRouter(subsystem)
+	if err != nil {
+		return err
+	}
+
+	route := strings.TrimSuffix(prefix, "/") + "/*filepath"
+	if !strings.HasPrefix(prefix, "/") {
+		return fmt.Errorf("Invalid prefix %q: must start with a slash", prefix)
+	}
+	if path, conflicts := s.getPredefinedConflict(subsystem, route); conflicts {
+		return fmt.Errorf("Invalid prefix %q: conflicts with the %s route %s", prefix, subsystem, path)
+	}
+	if name == "" {
+		name = "root"
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Invalid prefix %q: %v", prefix, r)
+		}
+	}()
+
+	s.addRoute(router, subsystem, name, []string{route}, methods, middlewares, handler)
+	return nil
+}
+
+// withoutPredefinedPaths returns the routes that do not conflict with the predefined routes of the subsystem, which
+// are added when the service runs, and logs the conflicting ones.
+func (s *serviceImpl) withoutPredefinedPaths(subsystem, name string, routes []string) []string {
+	var valid []string
+	for _, route := range routes {
+		if path, conflicts := s.getPredefinedConflict(subsystem, route); conflicts {
+			s.log.Error("InvalidRoute", "Route %s of %s conflicts with the %s route %s", route, name, subsystem, path)
+			continue
+		}
+		valid = append(valid, route)
+	}
+	return valid
+}
+
+// getPredefinedConflict returns the predefined path of the subsystem that conflicts with the route, if any. Routes
+// conflict when they are equal, or when a wildcard of the route covers the predefined path.
+func (s *serviceImpl) getPredefinedConflict(subsystem, route string) (string, bool) {
+	wildcard := strings.IndexAny(route, ":*")
+
+	for _, path := range s.getPredefinedPaths(subsystem) {
+		switch {
+		case wildcard < 0:
+			if route == path {
+				return path, true
+			}
+		case route[wildcard] == '*':
+			if strings.HasPrefix(path, route[:wildcard]) {
+				return path, true
+			}
+		default:
+			if strings.HasPrefix(path, route[:wildcard]) && len(path) > wildcard {
+				return path, true
+			}
+		}
+	}
+	return "", false
+}
+
+// getPredefinedPaths returns the paths of the routes that are added to the subsystem when the service runs.
+func (s *serviceImpl) getPredefinedPaths(subsystem string) []string {
+	switch subsystem {
+	case PublicSubsystem:
+		paths := []string{"/service/version", "/service/liveness", "/service/readiness"}
+		if s.usePublicRootHandler {
+			paths = append(paths, "/")
+		}
+		if s.useCSPReportHandler {
+			paths = append(paths, CSPReportPath)
+		}
+		return paths
+	case ReadinessSubsystem:
+		return []string{"/", "/service/liveness", "/service/readiness"}
+	default:
+		return []string{"/", "/health_check", "/healthz", "/metrics", "/quit", "/cache"}
+	}
+}
+
+func (s *serviceImpl) getRouter(subsystem string) (Router, error) {
+	switch subsystem {
+	case PublicSubsystem:
+		return s.publicRouter, nil
+	case ReadinessSubsystem:
+		return s.readinessRouter, nil
+	case InternalSubsystem:
+		return s.internalRouter, nil
+	}
+	return nil, fmt.Errorf("Unknown subsystem: %s", subsystem)
+}
+
+func (s *serviceImpl) addRoute(router Router, subsystem, name string, routes []string, methods []string, middlewares []Middleware, handler Handle) {
 	defaultMetaFunc := func(_ *http.Request, _ RouterParams) map[string]string {
 		return make(map[string]string)
 	}
 
+	middlewares = s.getMiddlewares(subsystem, name, middlewares)
+
 	for _, path := range routes {
 		wrappedHandler := s.wrapHandler.Wrap(subsystem, name, middlewares, handler, defaultMetaFunc)
 
 		for _, method := range methods {
-			router.Router.Handle(method, path, wrappedHandler)
+			router.Handle(method, path, wrappedHandler)
 		}
 	}
 }
 
-func (s *serviceImpl) addRouteWithMetaAndPreFlight(router *Router, subsystem, name string, routes []string, methods []string,
+func (s *serviceImpl) addRouteWithMetaAndPreFlight(router Router, subsystem, name string, routes []string, methods []string,
 	middlewares []Middleware, metaFunc MetaFunc, handler Handle) {
 
+	middlewares = s.getMiddlewares(subsystem, name, middlewares)
+
 	for _, path := range routes {
 		wrappedHandler := s.wrapHandler.Wrap(subsystem, name, middlewares, handler, metaFunc)
 		preFlightHandled := false
 
 		for _, method := range methods {
-			router.Router.Handle(method, path, wrappedHandler)
+			router.Handle(method, path, wrappedHandler)
 			preFlightHandled = preFlightHandled || method == http.MethodOptions
 		}
 
@@ -339,8 +537,39 @@ func (s *serviceImpl) addRouteWithMetaAndPreFlight(router *Router, subsystem, na
 	}
 }
 
+// getMiddlewares returns the middleware of a route, including the global middleware, in the order expected by
+// WrapHandler, which applies the first middleware as the innermost wrapper.
+func (s *serviceImpl) getMiddlewares(subsystem, name string, middlewares []Middleware) []Middleware {
+	if subsystem == PublicSubsystem && len(s.globalMiddlewares) > 0 && !s.globalExclusions[name] {
+		if s.middlewareOrder == OuterToInner {
+			middlewares = append(append([]Middleware{}, s.globalMiddlewares...), middlewares...)
+		} else {
+			middlewares = append(append([]Middleware{}, middlewares...), s.globalMiddlewares...)
+		}
+	}
+
+	if s.middlewareOrder != OuterToInner {
+		return middlewares
+	}
+
+	reversed := make([]Middleware, len(middlewares))
+
+	for i, m := range middlewares {
+		reversed[len(middlewares)-1-i] = m
+	}
+	return reversed
+}
+
+// getDefaultMiddlewares returns the middleware for the predefined endpoints of the specified subsystem.
+func (s *serviceImpl) getDefaultMiddlewares(subsystem string) []Middleware {
+	if middlewares, ok := s.subsystemMiddlewares[subsystem]; ok {
+		return middlewares
+	}
+	return DefaultMiddlewares
+}
+
 func (s *serviceImpl) addPreFlightHandle(subsystem string, name string, middlewares []Middleware, metaFunc MetaFunc,
-	router *Router, path string) {
+	router Router, path string) {
 
 	preFlightMiddlewares := []Middleware{Counter}
 
@@ -354,17 +583,17 @@ func (s *serviceImpl) addPreFlightHandle(subsystem string, name string, middlewa
 	preFlightHandler := s.handlers.PreFlightHandler.NewPreFlightHandler()
 	wrappedPreFlightHandler := s.wrapHandler.Wrap(subsystem, fmt.Sprintf("%v-preflight", name),
 		preFlightMiddlewares, preFlightHandler, metaFunc)
-	router.Router.Handle(http.MethodOptions, path, wrappedPreFlightHandler)
+	router.Handle(http.MethodOptions, path, wrappedPreFlightHandler)
 }
 
-func (s *serviceImpl) runHTTPServer(port int, router *Router) {
+func (s *serviceImpl) runHTTPServer(port int, router Router) {
 	addr := fmt.Sprintf(":%v", port)
 	svr := &http.Server{
 		ReadTimeout:  s.serverTimeout,
 		WriteTimeout: s.serverTimeout,
 		IdleTimeout:  s.idleTimeout,
 		Addr:         addr,
-		Handler:      router.Router,
+		Handler:      router,
 	}
 
 	go func() {
@@ -393,13 +622,14 @@ func (s *serviceImpl) runHTTPServer(port int, router *Router) {
 
 // RunReadinessServer runs the readiness service as a go-routine
 func (s *serviceImpl) runReadinessServer() {
-	const subsystem = "readiness"
+	const subsystem = ReadinessSubsystem
 
 	router := s.readinessRouter
+	middlewares := s.getDefaultMiddlewares(subsystem)
 
-	s.addRoute(router, subsystem, "root", []string{"/"}, MethodsForGet, DefaultMiddlewares, s.handlers.RootHandler.NewRootHandler())
-	s.addRoute(router, subsystem, "liveness", []string{"/service/liveness"}, MethodsForGet, DefaultMiddlewares, s.handlers.LivenessHandler.NewLivenessHandler())
-	s.addRoute(router, subsystem, "readiness", []string{"/service/readiness"}, MethodsForGet, DefaultMiddlewares, s.handlers.ReadinessHandler.NewReadinessHandler())
+	s.addRoute(router, subsystem, "root", []string{"/"}, MethodsForGet, middlewares, s.handlers.RootHandler.NewRootHandler())
+	s.addRoute(router, subsystem, "liveness", []string{"/service/liveness"}, MethodsForGet, middlewares, s.handlers.LivenessHandler.NewLivenessHandler())
+	s.addRoute(router, subsystem, "readiness", []string{"/service/readiness"}, MethodsForGet, middlewares, s.handlers.ReadinessHandler.NewReadinessHandler())
 
 	s.log.Info("RunReadinessServer", "%s %s running on localhost:%d.", s.globals.AppName, subsystem, s.readinessPort)
 
@@ -408,14 +638,20 @@ func (s *serviceImpl) runReadinessServer() {
 
 // RunInternalServer runs the internal service as a go-routine
 func (s *serviceImpl) runInternalServer() {
-	const subsystem = "internal"
+	const subsystem = InternalSubsystem
 
 	router := s.internalRouter
+	middlewares := s.getDefaultMiddlewares(subsystem)
+
+	s.addRoute(router, subsystem, "root", []string{"/"}, MethodsForGet, middlewares, s.handlers.RootHandler.NewRootHandler())
+	s.addRoute(router, subsystem, "health_check", []string{"/health_check", "/healthz"}, MethodsForGet, middlewares, s.handlers.HealthHandler.NewHealthHandler())
+	s.addRoute(router, subsystem, "metrics", []string{"/metrics"}, MethodsForGet, middlewares, s.handlers.MetricsHandler.NewMetricsHandler())
+	s.addRoute(router, subsystem, "quit", []string{"/quit"}, MethodsForGet, middlewares, s.handlers.QuitHandler.NewQuitHandler())
 
-	s.addRoute(router, subsystem, "root", []string{"/"}, MethodsForGet, DefaultMiddlewares, s.handlers.RootHandler.NewRootHandler())
-	s.addRoute(router, subsystem, "health_check", []string{"/health_check", "/healthz"}, MethodsForGet, DefaultMiddlewares, s.handlers.HealthHandler.NewHealthHandler())
-	s.addRoute(router, subsystem, "metrics", []string{"/metrics"}, MethodsForGet, DefaultMiddlewares, s.handlers.MetricsHandler.NewMetricsHandler())
-	s.addRoute(router, subsystem, "quit", []string{"/quit"}, MethodsForGet, DefaultMiddlewares, s.handlers.QuitHandler.NewQuitHandler())
+	if len(s.responseCaches) > 0 {
+		s.addRoute(router, subsystem, "cache_purge", []string{"/cache"}, []string{http.MethodDelete}, middlewares,
+			NewResponseCachePurgeHandler(s.responseCaches))
+	}
 
 	s.log.Info("RunInternalServer", "%s %s running on localhost:%d.", s.globals.AppName, subsystem, s.internalPort)
 
@@ -425,15 +661,19 @@ func (s *serviceImpl) runInternalServer() {
 // RunPublicServer runs the public service on the current thread.
 func (s *serviceImpl) runPublicServer() {
 	router := s.publicRouter
+	middlewares := s.getDefaultMiddlewares(PublicSubsystem)
 
 	if s.usePublicRootHandler {
-		s.addRoute(router, publicSubsystem, "root", []string{"/"}, MethodsForGet, DefaultMiddlewares, s.handlers.RootHandler.NewRootHandler())
+		s.addRoute(router, PublicSubsystem, "root", []string{"/"}, MethodsForGet, middlewares, s.handlers.RootHandler.NewRootHandler())
+	}
+	s.addRoute(router, PublicSubsystem, "version", []string{"/service/version"}, MethodsForGet, middlewares, s.handlers.VersionHandler.NewVersionHandler())
+	s.addRoute(router, PublicSubsystem, "liveness", []string{"/service/liveness"}, MethodsForGet, middlewares, s.handlers.LivenessHandler.NewLivenessHandler())
+	s.addRoute(router, PublicSubsystem, "readiness", []string{"/service/readiness"}, MethodsForGet, middlewares, s.handlers.ReadinessHandler.NewReadinessHandler())
+	if s.useCSPReportHandler {
+		s.addRoute(router, PublicSubsystem, "csp_report", []string{CSPReportPath}, MethodsForPost, middlewares, NewCSPReportHandler(s.log, s.metrics))
 	}
-	s.addRoute(router, publicSubsystem, "version", []string{"/service/version"}, MethodsForGet, DefaultMiddlewares, s.handlers.VersionHandler.NewVersionHandler())
-	s.addRoute(router, publicSubsystem, "liveness", []string{"/service/liveness"}, MethodsForGet, DefaultMiddlewares, s.handlers.LivenessHandler.NewLivenessHandler())
-	s.addRoute(router, publicSubsystem, "readiness", []string{"/service/readiness"}, MethodsForGet, DefaultMiddlewares, s.handlers.ReadinessHandler.NewReadinessHandler())
 
-	s.log.Info("RunPublicService", "%s %s running on localhost:%d.", s.globals.AppName, publicSubsystem, s.port)
+	s.log.Info("RunPublicService", "%s %s running on localhost:%d.", s.globals.AppName, PublicSubsystem, s.port)
 
 	s.runHTTPServer(s.port, router)
 }
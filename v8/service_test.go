@@ -3,11 +3,12 @@ package v8_test
 import (
 	"fmt"
 	"net/http"
+	"sync"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	sf "github.com/Travix-International/go-servicefoundation/v8"
-	"github.com/julienschmidt/httprouter"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"golang.org/x/net/context"
@@ -35,9 +36,7 @@ func TestServiceImpl_AddRoute(t *testing.T) {
 	handlers := &sf.Handlers{
 		PreFlightHandler: preFlightH,
 	}
-	router := &sf.Router{
-		Router: &httprouter.Router{},
-	}
+	router := sf.NewRouterFactory().NewRouter()
 	opt := sf.ServiceOptions{
 		Globals: sf.ServiceGlobals{
 			AppName: "test-service",
@@ -55,7 +54,7 @@ func TestServiceImpl_AddRoute(t *testing.T) {
 		ServerTimeout:  time.Second * 3,
 		IdleTimeout:    time.Second * 3,
 	}
-	var wrappedHandle httprouter.Handle = func(http.ResponseWriter, *http.Request, httprouter.Params) {}
+	var wrappedHandle sf.RouterHandle = func(http.ResponseWriter, *http.Request, sf.RouterParams) {}
 	metaFunc := func(*http.Request, sf.RouterParams) map[string]string {
 		return make(map[string]string)
 	}
@@ -100,9 +99,7 @@ func TestServiceImpl_AddRouteWithCORS(t *testing.T) {
 	handlers := &sf.Handlers{
 		PreFlightHandler: preFlightH,
 	}
-	router := &sf.Router{
-		Router: &httprouter.Router{},
-	}
+	router := sf.NewRouterFactory().NewRouter()
 	opt := sf.ServiceOptions{
 		Globals: sf.ServiceGlobals{
 			AppName: "test-service",
@@ -120,7 +117,7 @@ func TestServiceImpl_AddRouteWithCORS(t *testing.T) {
 		ServerTimeout:  time.Second * 3,
 		IdleTimeout:    time.Second * 3,
 	}
-	var wrappedHandle httprouter.Handle = func(http.ResponseWriter, *http.Request, httprouter.Params) {}
+	var wrappedHandle sf.RouterHandle = func(http.ResponseWriter, *http.Request, sf.RouterParams) {}
 	metaFunc := func(*http.Request, sf.RouterParams) map[string]string {
 		return make(map[string]string)
 	}
@@ -173,9 +170,7 @@ func TestServiceImpl_AddRouteWithHandledPreFlight(t *testing.T) {
 	handlers := &sf.Handlers{
 		PreFlightHandler: preFlightH,
 	}
-	router := &sf.Router{
-		Router: &httprouter.Router{},
-	}
+	router := sf.NewRouterFactory().NewRouter()
 	opt := sf.ServiceOptions{
 		Globals: sf.ServiceGlobals{
 			AppName: "test-service",
@@ -193,7 +188,7 @@ func TestServiceImpl_AddRouteWithHandledPreFlight(t *testing.T) {
 		ServerTimeout:  time.Second * 3,
 		IdleTimeout:    time.Second * 3,
 	}
-	var wrappedHandle httprouter.Handle = func(http.ResponseWriter, *http.Request, httprouter.Params) {}
+	var wrappedHandle sf.RouterHandle = func(http.ResponseWriter, *http.Request, sf.RouterParams) {}
 	metaFunc := func(*http.Request, sf.RouterParams) map[string]string {
 		return make(map[string]string)
 	}
@@ -220,24 +215,284 @@ func TestServiceImpl_AddRouteWithHandledPreFlight(t *testing.T) {
 	rf.AssertExpectations(t)
 }
 
-func TestServiceImpl_Run(t *testing.T) {
+func TestServiceImpl_AddSubsystemRoutes(t *testing.T) {
+	scenarios := []struct {
+		subsystem string
+		addRoute  func(sf.Service, string, []string, []string, []sf.Middleware, sf.MetaFunc, sf.Handle)
+	}{
+		{"readiness", sf.Service.AddReadinessRoute},
+		{"internal", sf.Service.AddInternalRoute},
+	}
+
+	for _, scenario := range scenarios {
+		logFactory := &mockLogFactory{}
+		log := &mockLogger{}
+		rf := &mockRouterFactory{}
+		shf := &mockServiceHandlerFactory{}
+		preFlightH := &mockPreFlightHandler{}
+
+		handlers := &sf.Handlers{
+			PreFlightHandler: preFlightH,
+		}
+		router := sf.NewRouterFactory().NewRouter()
+		opt := sf.ServiceOptions{
+			LogFactory:    logFactory,
+			RouterFactory: rf,
+			Handlers:      handlers,
+			WrapHandler:   shf,
+		}
+		var wrappedHandle sf.RouterHandle = func(http.ResponseWriter, *http.Request, sf.RouterParams) {}
+		metaFunc := func(*http.Request, sf.RouterParams) map[string]string {
+			return make(map[string]string)
+		}
+		var preFlightHandle sf.Handle = func(sf.WrappedResponseWriter, *http.Request, sf.RouterParams) {}
+		handle := func(sf.WrappedResponseWriter, *http.Request, sf.RouterParams) {}
+		middlewares := sf.DefaultMiddlewares
+
+		logFactory.On("NewLogger", mock.Anything).Return(log)
+		shf.
+			On("Wrap", scenario.subsystem, "flush", middlewares, mock.AnythingOfType("Handle"), mock.AnythingOfType("MetaFunc")).
+			Return(wrappedHandle).
+			Once()
+		shf.
+			On("Wrap", scenario.subsystem, "flush-preflight", mock.Anything, mock.AnythingOfType("Handle"), mock.AnythingOfType("MetaFunc")).
+			Return(wrappedHandle).
+			Once()
+		rf.
+			On("NewRouter").
+			Return(router).
+			Times(3) // public, readiness and internal
+		preFlightH.On("NewPreFlightHandler").Return(preFlightHandle)
+
+		sut := sf.NewCustomService(opt)
+
+		// Act
+		scenario.addRoute(sut, "flush", []string{"/flush"}, []string{http.MethodPost}, middlewares, metaFunc, handle)
+
+		shf.AssertExpectations(t)
+		rf.AssertExpectations(t)
+		preFlightH.AssertExpectations(t)
+	}
+}
+
+func TestServiceImpl_AddSubsystemRoutes_Predefined(t *testing.T) {
+	scenarios := []struct {
+		addRoute       func(sf.Service, string, []string, []string, []sf.Middleware, sf.MetaFunc, sf.Handle)
+		routes         []string
+		expectedWraps  int
+		expectedErrors int
+	}{
+		{sf.Service.AddInternalRoute, []string{"/metrics"}, 0, 1},
+		{sf.Service.AddInternalRoute, []string{"/metrics", "/flush"}, 2, 1},
+		{sf.Service.AddInternalRoute, []string{"/cache/:name"}, 2, 0},
+		{sf.Service.AddReadinessRoute, []string{"/:name"}, 0, 1},
+		{sf.Service.AddReadinessRoute, []string{"/service/liveness"}, 0, 1},
+	}
+
+	for _, scenario := range scenarios {
+		logFactory := &mockLogFactory{}
+		log := &mockLogger{}
+		shf := &mockServiceHandlerFactory{}
+		preFlightH := &mockPreFlightHandler{}
+		opt := sf.ServiceOptions{
+			LogFactory:    logFactory,
+			RouterFactory: sf.NewRouterFactory(),
+			Handlers:      &sf.Handlers{PreFlightHandler: preFlightH},
+			WrapHandler:   shf,
+		}
+		var wrappedHandle sf.RouterHandle = func(http.ResponseWriter, *http.Request, sf.RouterParams) {}
+		var preFlightHandle sf.Handle = func(sf.WrappedResponseWriter, *http.Request, sf.RouterParams) {}
+		handle := func(sf.WrappedResponseWriter, *http.Request, sf.RouterParams) {}
+
+		logFactory.On("NewLogger", mock.Anything).Return(log)
+		log.On("Error", "InvalidRoute", mock.Anything, mock.Anything).Return()
+		shf.On("Wrap", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Return(wrappedHandle)
+		preFlightH.On("NewPreFlightHandler").Return(preFlightHandle)
+
+		sut := sf.NewCustomService(opt)
+
+		// Act
+		scenario.addRoute(sut, "flush", scenario.routes, []string{http.MethodPost}, nil, nil, handle)
+
+		shf.AssertNumberOfCalls(t, "Wrap", scenario.expectedWraps)
+		log.AssertNumberOfCalls(t, "Error", scenario.expectedErrors)
+	}
+}
+
+func TestServiceImpl_Mount(t *testing.T) {
 	logFactory := &mockLogFactory{}
 	log := &mockLogger{}
-	m := &mockMetrics{}
-	v := &mockVersionBuilder{}
 	rf := &mockRouterFactory{}
 	shf := &mockServiceHandlerFactory{}
+	opt := sf.ServiceOptions{
+		LogFactory:    logFactory,
+		RouterFactory: rf,
+		WrapHandler:   shf,
+	}
+	var wrappedHandle sf.RouterHandle = func(http.ResponseWriter, *http.Request, sf.RouterParams) {}
+	middlewares := []sf.Middleware{sf.RequestMetrics}
+
+	logFactory.On("NewLogger", mock.Anything).Return(log)
+	shf.
+		On("Wrap", "internal", "debug_pprof", middlewares, mock.AnythingOfType("Handle"), mock.AnythingOfType("MetaFunc")).
+		Return(wrappedHandle).
+		Twice()
+	rf.
+		On("NewRouter").
+		Return(sf.NewRouterFactory().NewRouter()).
+		Times(3) // public, readiness and internal
+
+	sut := sf.NewCustomService(opt)
+
+	// Act
+	err := sut.Mount(sf.InternalSubsystem, "/debug/pprof/", http.NotFoundHandler(), middlewares...)
+	conflict := sut.Mount(sf.InternalSubsystem, "/debug/pprof", http.NotFoundHandler(), middlewares...)
 
-	publicRouter := &sf.Router{
-		Router: &httprouter.Router{},
+	assert.NoError(t, err)
+	if assert.Error(t, conflict) {
+		assert.Contains(t, conflict.Error(), `Invalid prefix "/debug/pprof": `)
 	}
-	readinessRouter := &sf.Router{
-		Router: &httprouter.Router{},
+	shf.AssertExpectations(t)
+	rf.AssertExpectations(t)
+}
+
+func TestServiceImpl_Mount_Invalid(t *testing.T) {
+	scenarios := []struct {
+		subsystem string
+		prefix    string
+		expected  string
+	}{
+		{"unknown", "/debug/pprof", "Unknown subsystem: unknown"},
+		{sf.PublicSubsystem, "debug", `Invalid prefix "debug": must start with a slash`},
+		{sf.PublicSubsystem, "/", `Invalid prefix "/": conflicts with the public route /service/version`},
+		{sf.PublicSubsystem, "/service/", `Invalid prefix "/service/": conflicts with the public route /service/version`},
+		{sf.ReadinessSubsystem, "/service", `Invalid prefix "/service": conflicts with the readiness route /service/liveness`},
+		{sf.InternalSubsystem, "/", `Invalid prefix "/": conflicts with the internal route /`},
+	}
+
+	for i, scenario := range scenarios {
+		logFactory := &mockLogFactory{}
+		log := &mockLogger{}
+		opt := sf.ServiceOptions{
+			LogFactory:    logFactory,
+			RouterFactory: sf.NewRouterFactory(),
+			WrapHandler:   &mockServiceHandlerFactory{},
+		}
+
+		logFactory.On("NewLogger", mock.Anything).Return(log)
+
+		sut := sf.NewCustomService(opt)
+
+		// Act
+		err := sut.Mount(scenario.subsystem, scenario.prefix, http.NotFoundHandler())
+
+		assert.EqualError(t, err, scenario.expected, "Scenario %d", i)
+	}
+}
+
+func TestServiceImpl_AddStaticRoute(t *testing.T) {
+	logFactory := &mockLogFactory{}
+	log := &mockLogger{}
+	rf := &mockRouterFactory{}
+	shf := &mockServiceHandlerFactory{}
+	opt := sf.ServiceOptions{
+		LogFactory:    logFactory,
+		RouterFactory: rf,
+		WrapHandler:   shf,
+	}
+	var wrappedHandle sf.RouterHandle = func(http.ResponseWriter, *http.Request, sf.RouterParams) {}
+
+	logFactory.On("NewLogger", mock.Anything).Return(log)
+	shf.
+		On("Wrap", "public", "static", []sf.Middleware(nil), mock.AnythingOfType("Handle"), mock.AnythingOfType("MetaFunc")).
+		Return(wrappedHandle).
+		Once()
+	rf.
+		On("NewRouter").
+		Return(sf.NewRouterFactory().NewRouter()).
+		Times(3) // public, readiness and internal
+
+	sut := sf.NewCustomService(opt)
+
+	// Act
+	err := sut.AddStaticRoute("/assets", fstest.MapFS{}, sf.StaticOptions{})
+
+	assert.NoError(t, err)
+	shf.AssertExpectations(t)
+	rf.AssertExpectations(t)
+}
+
+func TestServiceImpl_AddRouteWithGlobalMiddlewares(t *testing.T) {
+	scenarios := []struct {
+		order      sf.MiddlewareOrder
+		routeName  string
+		route      []sf.Middleware
+		expected   []sf.Middleware
+		exclusions []string
+	}{
+		{sf.InnerToOuter, "do", []sf.Middleware{sf.CORS, sf.PanicTo500}, []sf.Middleware{sf.CORS, sf.PanicTo500, sf.RequestMetrics, sf.RequestLogging}, nil},
+		{sf.OuterToInner, "do", []sf.Middleware{sf.PanicTo500, sf.CORS}, []sf.Middleware{sf.CORS, sf.PanicTo500, sf.RequestLogging, sf.RequestMetrics}, nil},
+		{sf.OuterToInner, "do", []sf.Middleware{sf.PanicTo500, sf.CORS}, []sf.Middleware{sf.CORS, sf.PanicTo500}, []string{"do"}},
 	}
-	internalRouter := &sf.Router{
-		Router: &httprouter.Router{},
+
+	for i, scenario := range scenarios {
+		logFactory := &mockLogFactory{}
+		log := &mockLogger{}
+		rf := &mockRouterFactory{}
+		shf := &mockServiceHandlerFactory{}
+		preFlightH := &mockPreFlightHandler{}
+		opt := sf.ServiceOptions{
+			LogFactory:                 logFactory,
+			RouterFactory:              rf,
+			Handlers:                   &sf.Handlers{PreFlightHandler: preFlightH},
+			WrapHandler:                shf,
+			MiddlewareOrder:            scenario.order,
+			GlobalMiddlewares:          []sf.Middleware{sf.RequestMetrics, sf.RequestLogging},
+			GlobalMiddlewareExclusions: scenario.exclusions,
+		}
+		var wrappedHandle sf.RouterHandle = func(http.ResponseWriter, *http.Request, sf.RouterParams) {}
+		var preFlightHandle sf.Handle = func(sf.WrappedResponseWriter, *http.Request, sf.RouterParams) {}
+		handle := func(sf.WrappedResponseWriter, *http.Request, sf.RouterParams) {}
+		var actual []sf.Middleware
+
+		logFactory.On("NewLogger", mock.Anything).Return(log)
+		shf.
+			On("Wrap", "public", scenario.routeName, mock.Anything, mock.AnythingOfType("Handle"), mock.AnythingOfType("MetaFunc")).
+			Run(func(args mock.Arguments) {
+				actual = args.Get(2).([]sf.Middleware)
+			}).
+			Return(wrappedHandle).
+			Once()
+		shf.
+			On("Wrap", "public", "do-preflight", mock.Anything, mock.AnythingOfType("Handle"), mock.AnythingOfType("MetaFunc")).
+			Return(wrappedHandle).
+			Once()
+		rf.On("NewRouter").Return(sf.NewRouterFactory().NewRouter())
+		preFlightH.On("NewPreFlightHandler").Return(preFlightHandle)
+
+		sut := sf.NewCustomService(opt)
+
+		// Act
+		sut.AddRoute(scenario.routeName, []string{"/do"}, sf.MethodsForGet, scenario.route, nil, handle)
+
+		assert.Equal(t, scenario.expected, actual, "Scenario %d", i)
+		shf.AssertExpectations(t)
 	}
-	var wrappedHandle httprouter.Handle = func(http.ResponseWriter, *http.Request, httprouter.Params) {}
+}
+
+func TestServiceImpl_Run(t *testing.T) {
+	logFactory := &mockLogFactory{}
+	log := &mockLogger{}
+	m := &mockMetrics{}
+	v := &mockVersionBuilder{}
+	rf := &mockRouterFactory{}
+	shf := &mockServiceHandlerFactory{}
+
+	publicRouter := sf.NewRouterFactory().NewRouter()
+	readinessRouter := sf.NewRouterFactory().NewRouter()
+	internalRouter := sf.NewRouterFactory().NewRouter()
+	var wrappedHandle sf.RouterHandle = func(http.ResponseWriter, *http.Request, sf.RouterParams) {}
 	var handle sf.Handle = func(sf.WrappedResponseWriter, *http.Request, sf.RouterParams) {}
 
 	quitH := &mockQuitHandler{}
@@ -336,16 +591,10 @@ func TestServiceImpl_Run_NoPublicRootHandler(t *testing.T) {
 	rf := &mockRouterFactory{}
 	shf := &mockServiceHandlerFactory{}
 
-	publicRouter := &sf.Router{
-		Router: &httprouter.Router{},
-	}
-	readinessRouter := &sf.Router{
-		Router: &httprouter.Router{},
-	}
-	internalRouter := &sf.Router{
-		Router: &httprouter.Router{},
-	}
-	var wrappedHandle httprouter.Handle = func(http.ResponseWriter, *http.Request, httprouter.Params) {}
+	publicRouter := sf.NewRouterFactory().NewRouter()
+	readinessRouter := sf.NewRouterFactory().NewRouter()
+	internalRouter := sf.NewRouterFactory().NewRouter()
+	var wrappedHandle sf.RouterHandle = func(http.ResponseWriter, *http.Request, sf.RouterParams) {}
 	var handle sf.Handle = func(sf.WrappedResponseWriter, *http.Request, sf.RouterParams) {}
 
 	quitH := &mockQuitHandler{}
@@ -436,6 +685,65 @@ func TestServiceImpl_Run_NoPublicRootHandler(t *testing.T) {
 	versionH.AssertExpectations(t)
 }
 
+func TestServiceImpl_Run_SubsystemMiddlewares(t *testing.T) {
+	logFactory := &mockLogFactory{}
+	log := &mockLogger{}
+	v := &mockVersionBuilder{}
+	rf := &mockRouterFactory{}
+	shf := &mockServiceHandlerFactory{}
+	var wrappedHandle sf.RouterHandle = func(http.ResponseWriter, *http.Request, sf.RouterParams) {}
+	internalMiddlewares := []sf.Middleware{sf.PanicTo500, sf.RequestMetrics}
+	wrapped := make(map[string][]sf.Middleware)
+	wrappedMutex := &sync.Mutex{}
+
+	handlerFactory := sf.NewServiceHandlerFactory(&mockMiddlewareWrapper{}, v, &mockServiceStateReader{}, func(int) {})
+	handlers := handlerFactory.NewHandlers()
+
+	logFactory.On("NewLogger", mock.Anything).Return(log)
+	log.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	log.On("Debug", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	v.On("ToString").Return("(version)")
+	shf.
+		On("Wrap", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			wrappedMutex.Lock()
+			defer wrappedMutex.Unlock()
+			wrapped[args.String(0)+"/"+args.String(1)] = args.Get(2).([]sf.Middleware)
+		}).
+		Return(wrappedHandle)
+	rf.On("NewRouter").Return(sf.NewRouterFactory().NewRouter()).Once()
+	rf.On("NewRouter").Return(sf.NewRouterFactory().NewRouter()).Once()
+	rf.On("NewRouter").Return(sf.NewRouterFactory().NewRouter()).Once()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	opt := sf.ServiceOptions{
+		LogFactory:     logFactory,
+		Port:           1244,
+		ReadinessPort:  1245,
+		InternalPort:   1246,
+		VersionBuilder: v,
+		RouterFactory:  rf,
+		Handlers:       handlers,
+		WrapHandler:    shf,
+		ExitFunc:       func(int) {},
+		SubsystemMiddlewares: map[string][]sf.Middleware{
+			sf.InternalSubsystem: internalMiddlewares,
+		},
+	}
+	sut := sf.NewCustomService(opt)
+
+	// Act
+	go sut.Run(ctx)
+
+	time.Sleep(11 * time.Millisecond)
+	wrappedMutex.Lock()
+	defer wrappedMutex.Unlock()
+	assert.Equal(t, internalMiddlewares, wrapped["internal/metrics"])
+	assert.Equal(t, sf.DefaultMiddlewares, wrapped["readiness/liveness"])
+	assert.Equal(t, sf.DefaultMiddlewares, wrapped["public/version"])
+}
+
 func TestNewExitFunc(t *testing.T) {
 	logger := mockLogger{}
 	shutdownFn := func(log sf.Logger) {}
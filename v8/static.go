@@ -0,0 +1,237 @@
+package v8
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const defaultIndexFile = "index.html"
+
+type (
+	// StaticOptions contains properties used for serving static files with Service.AddStaticRoute.
+	StaticOptions struct {
+		// Subsystem is the subsystem serving the files. Default value is PublicSubsystem.
+		Subsystem string
+		// Name is used for identifying the route in metrics and log messages. Default value is "static".
+		Name string
+		// Middlewares contains the middleware wrappers for the route.
+		Middlewares []Middleware
+		// IndexFile is the file that is served for directories. Default value is "index.html".
+		IndexFile string
+		// SPAFallback serves the index file of the root directory for unknown paths without a file extension, so
+		// client-side routing of single-page applications keeps working.
+		SPAFallback bool
+		// Precompressed serves a .br or .gz variant of a file, when it exists and the client accepts it.
+		Precompressed bool
+		// MaxAge is the max-age in seconds passed to WrappedResponseWriter.SetCaching for files. When 0, caches are
+		// required to revalidate the file using its ETag or Last-Modified header.
+		MaxAge int
+		// IndexMaxAge is the max-age in seconds passed to WrappedResponseWriter.SetCaching for index files. When 0,
+		// caches are required to revalidate the index file using its ETag or Last-Modified header.
+		IndexMaxAge int
+	}
+
+	staticHandler struct {
+		fsys    fs.FS
+		options StaticOptions
+		etags   sync.Map
+	}
+
+	staticEncoding struct {
+		name      string
+		extension string
+	}
+)
+
+// staticEncodings contains the supported precompressed variants in order of preference.
+var staticEncodings = []staticEncoding{{"br", ".br"}, {"gzip", ".gz"}}
+
+// NewStaticHandler creates a Handle that serves files from the specified file system, which can be an os.DirFS or
+// an embed.FS. The file path is taken from the "filepath" path parameter.
+func NewStaticHandler(fsys fs.FS, options StaticOptions) Handle {
+	if options.IndexFile == "" {
+		options.IndexFile = defaultIndexFile
+	}
+
+	h := &staticHandler{
+		fsys:    fsys,
+		options: options,
+	}
+	return h.serve
+}
+
+func (h *staticHandler) serve(w WrappedResponseWriter, r *http.Request, p RouterParams) {
+	name := strings.TrimPrefix(path.Clean("/"+p.ByName("filepath")), "/")
+	if name == "" {
+		name = "."
+	}
+
+	name, info, err := h.resolve(name)
+
+	if errors.Is(err, fs.ErrNotExist) && h.options.SPAFallback && path.Ext(name) == "" {
+		name, info, err = h.resolve(".")
+	}
+
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	isIndex := path.Base(name) == h.options.IndexFile
+	h.setCaching(w, isIndex)
+
+	servedName := name
+	if h.options.Precompressed {
+		addVaryHeader(w.Header(), "Accept-Encoding")
+		servedName, info = h.negotiateEncoding(w, r, name, info)
+	}
+
+	content, err := h.readSeeker(servedName)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if c, ok := content.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+		w.Header().Set(ContentTypeHeader, ctype)
+	}
+	if etag := h.etag(servedName, info, content); etag != "" && w.Header().Get("ETag") == "" {
+		w.Header().Set("ETag", etag)
+	}
+
+	http.ServeContent(w, r, name, info.ModTime(), content)
+}
+
+// resolve returns the name and file info of the file to serve, using the index file for directories.
+func (h *staticHandler) resolve(name string) (string, fs.FileInfo, error) {
+	info, err := fs.Stat(h.fsys, name)
+	if err != nil {
+		return name, nil, err
+	}
+
+	if info.IsDir() {
+		name = path.Join(name, h.options.IndexFile)
+		info, err = fs.Stat(h.fsys, name)
+		if err == nil && info.IsDir() {
+			err = fs.ErrNotExist
+		}
+	}
+	return name, info, err
+}
+
+func (h *staticHandler) setCaching(w WrappedResponseWriter, isIndex bool) {
+	maxAge := h.options.MaxAge
+	if isIndex {
+		maxAge = h.options.IndexMaxAge
+	}
+
+	if maxAge > 0 {
+		w.SetCaching(maxAge)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+}
+
+// negotiateEncoding returns the name and file info of the precompressed variant accepted by the client, if any.
+func (h *staticHandler) negotiateEncoding(w WrappedResponseWriter, r *http.Request, name string,
+	info fs.FileInfo) (string, fs.FileInfo) {
+
+	accepted := r.Header.Get("Accept-Encoding")
+
+	for _, encoding := range staticEncodings {
+		if !acceptsEncoding(accepted, encoding.name) {
+			continue
+		}
+
+		variantInfo, err := fs.Stat(h.fsys, name+encoding.extension)
+		if err != nil || variantInfo.IsDir() {
+			continue
+		}
+
+		w.Header().Set("Content-Encoding", encoding.name)
+		return name + encoding.extension, variantInfo
+	}
+	return name, info
+}
+
+func (h *staticHandler) readSeeker(name string) (io.ReadSeeker, error) {
+	f, err := h.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if rs, ok := f.(io.ReadSeeker); ok {
+		return rs, nil
+	}
+
+	defer f.Close()
+
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(b), nil
+}
+
+// etag returns a weak ETag based on size and modification time, or a strong ETag based on the content for files
+// without a modification time, like the files of an embed.FS.
+func (h *staticHandler) etag(name string, info fs.FileInfo, content io.ReadSeeker) string {
+	if !info.ModTime().IsZero() {
+		return fmt.Sprintf(`W/"%x-%x"`, info.Size(), info.ModTime().UnixNano())
+	}
+
+	if etag, ok := h.etags.Load(name); ok {
+		return etag.(string)
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return ""
+	}
+	content.Seek(0, io.SeekStart)
+
+	etag := `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+	h.etags.Store(name, etag)
+	return etag
+}
+
+// acceptsEncoding returns whether the specified Accept-Encoding header value accepts the specified encoding.
+func acceptsEncoding(acceptEncoding, encoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+
+		if strings.TrimSpace(fields[0]) != encoding {
+			continue
+		}
+
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			if q, err := strconv.ParseFloat(param[2:], 64); err == nil && q == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
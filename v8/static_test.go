@@ -0,0 +1,87 @@
+package v8_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	sf "github.com/Travix-International/go-servicefoundation/v8"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func newStaticTestFS() fstest.MapFS {
+	return fstest.MapFS{
+		"index.html": {Data: []byte("<html>index</html>")},
+		"app.js":     {Data: []byte("console.log('app');"), ModTime: time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)},
+		"app.js.gz":  {Data: []byte("gzipped"), ModTime: time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)},
+	}
+}
+
+func serveStatic(sut sf.Handle, filepath string, header http.Header) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/static"+filepath, nil)
+	for key, values := range header {
+		r.Header[key] = values
+	}
+	sut(sf.NewWrappedResponseWriter(w), r, sf.RouterParams{Params: httprouter.Params{{Key: "filepath", Value: filepath}}})
+	return w
+}
+
+func TestNewStaticHandler_ServesFiles(t *testing.T) {
+	sut := sf.NewStaticHandler(newStaticTestFS(), sf.StaticOptions{MaxAge: 3600})
+
+	// Act
+	index := serveStatic(sut, "/", nil)
+	script := serveStatic(sut, "/app.js", nil)
+	missing := serveStatic(sut, "/unknown", nil)
+
+	assert.Equal(t, http.StatusOK, index.Code)
+	assert.Equal(t, "<html>index</html>", index.Body.String())
+	assert.Equal(t, "no-cache", index.Header().Get("Cache-Control"))
+	assert.NotEmpty(t, index.Header().Get("ETag"))
+	assert.Equal(t, http.StatusOK, script.Code)
+	assert.Equal(t, "public, max-age=3600", script.Header().Get("Cache-Control"))
+	assert.Equal(t, "Sat, 01 May 2021 00:00:00 GMT", script.Header().Get("Last-Modified"))
+	assert.Equal(t, http.StatusNotFound, missing.Code)
+}
+
+func TestNewStaticHandler_ConditionalAndRangeRequests(t *testing.T) {
+	sut := sf.NewStaticHandler(newStaticTestFS(), sf.StaticOptions{})
+	etag := serveStatic(sut, "/index.html", nil).Header().Get("ETag")
+
+	// Act
+	notModified := serveStatic(sut, "/index.html", http.Header{"If-None-Match": {etag}})
+	notModifiedSince := serveStatic(sut, "/app.js", http.Header{"If-Modified-Since": {"Sat, 01 May 2021 00:00:00 GMT"}})
+	partial := serveStatic(sut, "/index.html", http.Header{"Range": {"bytes=1-4"}})
+
+	assert.Equal(t, http.StatusNotModified, notModified.Code)
+	assert.Equal(t, http.StatusNotModified, notModifiedSince.Code)
+	assert.Equal(t, http.StatusPartialContent, partial.Code)
+	assert.Equal(t, "html", partial.Body.String())
+}
+
+func TestNewStaticHandler_PrecompressedAndFallbacks(t *testing.T) {
+	sut := sf.NewStaticHandler(newStaticTestFS(), sf.StaticOptions{
+		Precompressed: true,
+		SPAFallback:   true,
+		IndexFile:     "index.html",
+	})
+
+	// Act
+	gzipped := serveStatic(sut, "/app.js", http.Header{"Accept-Encoding": {"br;q=0, gzip"}})
+	plain := serveStatic(sut, "/app.js", nil)
+	spa := serveStatic(sut, "/users/42", nil)
+	missingAsset := serveStatic(sut, "/missing.js", nil)
+
+	assert.Equal(t, "gzipped", gzipped.Body.String())
+	assert.Equal(t, "gzip", gzipped.Header().Get("Content-Encoding"))
+	assert.Equal(t, "text/javascript; charset=utf-8", gzipped.Header().Get("Content-Type"))
+	assert.Equal(t, []string{"Accept-Encoding"}, gzipped.Header().Values("Vary"))
+	assert.Equal(t, "console.log('app');", plain.Body.String())
+	assert.Equal(t, "", plain.Header().Get("Content-Encoding"))
+	assert.Equal(t, "<html>index</html>", spa.Body.String())
+	assert.Equal(t, http.StatusNotFound, missingAsset.Code)
+}
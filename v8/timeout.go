@@ -0,0 +1,186 @@
+package v8
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type (
+	// TimeoutOptions contains properties used by the Timeout middleware.
+	TimeoutOptions struct {
+		// Duration is the maximum time a handler gets to respond.
+		Duration time.Duration
+		// StatusCode is the http status-code for requests that time out, http.StatusServiceUnavailable or
+		// http.StatusGatewayTimeout. Default value is http.StatusServiceUnavailable.
+		StatusCode int
+	}
+
+	// timeoutWriter passes writes of the handler to the response until the request has timed out. The handler gets
+	// its own header map, so a late handler can not modify the headers of a finished response.
+	timeoutWriter struct {
+		mutex    sync.Mutex
+		w        WrappedResponseWriter
+		header   http.Header
+		written  bool
+		timedOut bool
+	}
+)
+
+// Timeout returns a Middleware enumeration that attaches a deadline to the request context. When the handler has not
+// written anything before the deadline, the request gets http status-code 503 or 504 and later writes of the handler
+// fail with http.ErrHandlerTimeout. Handlers that did start writing are awaited, so they should watch the request
+// context. It panics when the duration is not positive.
+func Timeout(options TimeoutOptions) Middleware {
+	if options.Duration <= 0 {
+		panic(fmt.Errorf("Invalid timeout duration: %v", options.Duration))
+	}
+	if options.StatusCode == 0 {
+		options.StatusCode = http.StatusServiceUnavailable
+	}
+
+	return registerMiddleware("", func(m *middlewareWrapperImpl, subsystem, name string, handler Handle,
+		_ MetaFunc) Handle {
+
+		return m.wrapWithTimeout(subsystem, name, handler, options)
+	})
+}
+
+func (m *middlewareWrapperImpl) wrapWithTimeout(subsystem, name string, handler Handle, options TimeoutOptions) Handle {
+	return func(w WrappedResponseWriter, r *http.Request, p RouterParams) {
+		ctx, cancel := context.WithTimeout(r.Context(), options.Duration)
+		defer cancel()
+		r = r.WithContext(ctx)
+
+		tw := &timeoutWriter{w: w, header: w.Header().Clone()}
+		done := make(chan struct{})
+		panicked := make(chan interface{}, 1)
+
+		go func() {
+			defer func() {
+				if err := recover(); err != nil {
+					panicked <- err
+				}
+			}()
+
+			handler(NewWrappedResponseWriter(tw), r, p)
+			close(done)
+		}()
+
+		select {
+		case err := <-panicked:
+			// Panic in the request goroutine, so PanicTo500 can handle it.
+			panic(err)
+		case <-done:
+			tw.finish()
+		case <-ctx.Done():
+			if tw.timeout() {
+				writeErrorResponse(w, r, options.StatusCode, "Request timed out")
+
+				labels, values := m.getLabelsAndValues(subsystem, name, w, r)
+				m.metrics.CountLabels("", "http_requests_timeout_total", "Total requests that timed out.",
+					labels, values)
+				go m.logLatePanic(subsystem, name, done, panicked)
+				return
+			}
+
+			// The handler started writing; wait until it is done, since the response can not be replaced anymore.
+			select {
+			case err := <-panicked:
+				panic(err)
+			case <-done:
+			}
+		}
+	}
+}
+
+// logLatePanic logs a panic of a handler that timed out, since it can not be recovered in the request goroutine.
+func (m *middlewareWrapperImpl) logLatePanic(subsystem, name string, done chan struct{}, panicked chan interface{}) {
+	select {
+	case err := <-panicked:
+		m.log.Error("PanicAfterTimeout", "Handler %s/%s panicked after timing out: %v", subsystem, name, err)
+	case <-done:
+	}
+}
+
+// timeout marks the request as timed out, unless the handler started writing already.
+func (tw *timeoutWriter) timeout() bool {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+
+	if tw.written {
+		return false
+	}
+	tw.timedOut = true
+	return true
+}
+
+// finish sends the headers of a handler that did not write anything.
+func (tw *timeoutWriter) finish() {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+
+	if !tw.written && !tw.timedOut {
+		tw.commit(http.StatusOK)
+	}
+}
+
+// commit copies the headers of the handler to the response and writes the status-code.
+func (tw *timeoutWriter) commit(code int) {
+	tw.written = true
+
+	h := tw.w.Header()
+	for key := range h {
+		delete(h, key)
+	}
+	for key, values := range tw.header {
+		h[key] = values
+	}
+	tw.w.WriteHeader(code)
+}
+
+/* http.ResponseWriter implementation */
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+
+	if tw.timedOut || tw.written {
+		return
+	}
+	tw.commit(code)
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.written {
+		tw.commit(http.StatusOK)
+	}
+	return tw.w.Write(p)
+}
+
+func (tw *timeoutWriter) Flush() {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+
+	if tw.timedOut {
+		return
+	}
+	if !tw.written {
+		tw.commit(http.StatusOK)
+	}
+	if f, ok := tw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
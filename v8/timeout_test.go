@@ -0,0 +1,95 @@
+package v8_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sf "github.com/Travix-International/go-servicefoundation/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMiddlewareWrapperImpl_Wrap_Timeout(t *testing.T) {
+	scenarios := []struct {
+		statusCode     int
+		writeFirst     bool
+		delay          time.Duration
+		expectedStatus int
+		expectedBody   string
+	}{
+		{0, false, 0, http.StatusCreated, "done"},
+		{0, false, 50 * time.Millisecond, http.StatusServiceUnavailable, "{\"Message\":\"Request timed out\"}\n"},
+		{http.StatusGatewayTimeout, false, 50 * time.Millisecond, http.StatusGatewayTimeout,
+			"{\"Message\":\"Request timed out\"}\n"},
+		{0, true, 50 * time.Millisecond, http.StatusOK, "starteddone"},
+	}
+
+	for i, scenario := range scenarios {
+		logFactory := &mockLogFactory{}
+		log := &mockLogger{}
+		m := &mockMetrics{}
+		lateErr := make(chan error, 1)
+		handle := func(w sf.WrappedResponseWriter, r *http.Request, _ sf.RouterParams) {
+			w.Header().Set("X-Handler", "yes")
+			if scenario.writeFirst {
+				w.Write([]byte("started"))
+			}
+			time.Sleep(scenario.delay)
+			w.WriteHeader(http.StatusCreated)
+			_, err := w.Write([]byte("done"))
+			lateErr <- err
+		}
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "https://www.sf.com/some/url", nil)
+
+		logFactory.On("NewLogger", mock.Anything).Return(log)
+		m.On("CountLabels", "", "http_requests_timeout_total", mock.Anything, mock.Anything, mock.Anything).Maybe()
+
+		sut := sf.NewMiddlewareWrapper(logFactory, m, &sf.CORSOptions{}, sf.ServiceGlobals{})
+		middleware := sf.Timeout(sf.TimeoutOptions{Duration: 10 * time.Millisecond, StatusCode: scenario.statusCode})
+
+		// Act
+		sut.Wrap("my-sub", "my-name", middleware, handle, nil)(sf.NewWrappedResponseWriter(w), r, sf.RouterParams{})
+		err := <-lateErr
+
+		assert.Equal(t, scenario.expectedStatus, w.Code, "Scenario %d", i)
+		assert.Equal(t, scenario.expectedBody, w.Body.String(), "Scenario %d", i)
+		if scenario.expectedStatus == scenario.statusCode || scenario.expectedStatus == http.StatusServiceUnavailable {
+			assert.Equal(t, http.ErrHandlerTimeout, err, "Scenario %d", i)
+			assert.Equal(t, "", w.Header().Get("X-Handler"), "Scenario %d", i)
+			m.AssertNumberOfCalls(t, "CountLabels", 1)
+		} else {
+			assert.Nil(t, err, "Scenario %d", i)
+			assert.Equal(t, "yes", w.Header().Get("X-Handler"), "Scenario %d", i)
+		}
+	}
+}
+
+func TestMiddlewareWrapperImpl_Wrap_TimeoutWithPanic(t *testing.T) {
+	logFactory := &mockLogFactory{}
+	log := &mockLogger{}
+	m := &mockMetrics{}
+	handle := func(sf.WrappedResponseWriter, *http.Request, sf.RouterParams) {
+		panic("whoa")
+	}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "https://www.sf.com/some/url", nil)
+
+	logFactory.On("NewLogger", mock.Anything).Return(log)
+
+	sut := sf.NewMiddlewareWrapper(logFactory, m, &sf.CORSOptions{}, sf.ServiceGlobals{})
+	wrapped := sut.Wrap("my-sub", "my-name", sf.Timeout(sf.TimeoutOptions{Duration: time.Second}), handle, nil)
+
+	// Act
+	assert.PanicsWithValue(t, "whoa", func() {
+		wrapped(sf.NewWrappedResponseWriter(w), r, sf.RouterParams{})
+	})
+}
+
+func TestTimeout_InvalidDuration(t *testing.T) {
+	for i, duration := range []time.Duration{0, -time.Second} {
+		assert.Panics(t, func() { sf.Timeout(sf.TimeoutOptions{Duration: duration}) }, "Scenario %d", i)
+	}
+}